@@ -0,0 +1,139 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// AccessLogSettings
+// define where and how a single line per answered request is written. the
+// "common" and "combined" formats follow the Apache log formats, extended with
+// the trailing request ID, duration in milliseconds and result code fields.
+type AccessLogSettings struct {
+	Writer io.Writer // destination of the access log lines, nil to disable it
+	Format string    // line format (common, combined or json) default = combined
+}
+
+// accessLogEntry
+// define the fields of a JSON access log line.
+type accessLogEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	IP        string    `json:"ip"`
+	User      string    `json:"user,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Query     string    `json:"query,omitempty"`
+	Status    int       `json:"status"`
+	Size      int       `json:"size"`
+	Duration  float64   `json:"duration_ms"`
+	Code      string    `json:"code"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// writeAccessLog
+// write the access log line of an answered request.
+func (app *Application) writeAccessLog(r *APIRequest, res APIResponse) {
+	if app.AccessLogSettings.Writer == nil {
+		return
+	}
+
+	headers := headersCarrier(r.Headers)
+
+	entry := accessLogEntry{
+		Time:      time.Now(),
+		RequestID: r.ID,
+		IP:        r.IP,
+		User:      accessLogUser(r.User),
+		Method:    r.Method,
+		Path:      "/" + r.Path,
+		Query:     encodeQuery(r.Query),
+		Status:    res.HTTPCode,
+		Size:      len(res.Content),
+		Duration:  durationMilliseconds(r.duration),
+		Code:      r.Result.Code,
+		Referer:   headers.Get("Referer"),
+		UserAgent: headers.Get("User-Agent"),
+	}
+
+	// assemble the line in the requested format
+	var line []byte
+
+	switch app.AccessLogSettings.Format {
+	case "json":
+		var err error
+
+		line, err = json.Marshal(entry)
+		if err != nil {
+			r.Logger.Warn("failed to marshal the access log line", "err", err)
+			return
+		}
+	case "common":
+		line = []byte(fmt.Sprintf("%v %v", commonLogLine(entry), extendedLogFields(entry)))
+	default:
+		line = []byte(fmt.Sprintf("%v %q %q %v", commonLogLine(entry), clfField(entry.Referer), clfField(entry.UserAgent), extendedLogFields(entry)))
+	}
+
+	app.AccessLogSettings.Writer.Write(append(line, '\n'))
+}
+
+// commonLogLine
+// format an access log entry on the Common Log Format.
+func commonLogLine(e accessLogEntry) string {
+	target := e.Path
+	if e.Query != "" {
+		target += "?" + e.Query
+	}
+
+	return fmt.Sprintf("%v - %v [%v] %q %v %v",
+		clfField(e.IP),
+		clfField(e.User),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method+" "+target+" HTTP/1.1",
+		e.Status,
+		e.Size,
+	)
+}
+
+// extendedLogFields
+// format the request ID, duration in milliseconds and result code appended to the Common Log Format.
+func extendedLogFields(e accessLogEntry) string {
+	return fmt.Sprintf("%v %v %v", clfField(e.RequestID), strconv.FormatFloat(e.Duration, 'f', 3, 64), clfField(e.Code))
+}
+
+// clfField
+// return the "-" placeholder for the empty fields of the Common Log Format.
+func clfField(v string) string {
+	if v == "" {
+		return "-"
+	}
+
+	return v
+}
+
+// accessLogUser
+// return the authorized user representation for the access log.
+func accessLogUser(user interface{}) string {
+	attr, ok := userLogAttr(user)
+	if !ok {
+		return ""
+	}
+
+	return attr.Value.Resolve().String()
+}
+
+// encodeQuery
+// encode the request query parameters back into a query string.
+func encodeQuery(query map[string]string) string {
+	values := url.Values{}
+	for k, v := range query {
+		values.Set(k, v)
+	}
+
+	return values.Encode()
+}
@@ -0,0 +1,72 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestWriteAccessLog(t *testing.T) {
+	r := &APIRequest{
+		ID:       "req-1",
+		IP:       "10.0.0.1",
+		Method:   "GET",
+		Path:     "users",
+		Query:    map[string]string{"team": "a"},
+		Headers:  map[string]string{"Referer": "https://a.com/", "User-Agent": "curl/8.0"},
+		Result:   Result{Code: "OK"},
+		duration: 1500 * time.Microsecond,
+	}
+
+	res := APIResponse{HTTPCode: 200, Content: []byte(`{"ok":true}`)}
+
+	tests := []struct {
+		name   string
+		format string
+		line   string
+	}{
+		{"common", "common", `^10\.0\.0\.1 - - \[[^\]]+\] "GET /users\?team=a HTTP/1\.1" 200 11 req-1 1\.500 OK\n$`},
+		{"combined", "", `^10\.0\.0\.1 - - \[[^\]]+\] "GET /users\?team=a HTTP/1\.1" 200 11 "https://a\.com/" "curl/8\.0" req-1 1\.500 OK\n$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			app := newTestApplication()
+			app.AccessLogSettings = AccessLogSettings{Writer: &b, Format: tt.format}
+
+			app.writeAccessLog(r, res)
+
+			if !regexp.MustCompile(tt.line).MatchString(b.String()) {
+				t.Errorf("line = %q, want to match %v", b.String(), tt.line)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var b bytes.Buffer
+
+		app := newTestApplication()
+		app.AccessLogSettings = AccessLogSettings{Writer: &b, Format: "json"}
+
+		app.writeAccessLog(r, res)
+
+		var entry accessLogEntry
+		if err := json.Unmarshal(b.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+
+		if entry.RequestID != "req-1" || entry.Duration != 1.5 || entry.Code != "OK" || entry.Status != 200 || entry.Path != "/users" || entry.Query != "team=a" || entry.UserAgent != "curl/8.0" {
+			t.Errorf("entry = %+v", entry)
+		}
+	})
+
+	// the access log is disabled without a writer
+	t.Run("disabled", func(t *testing.T) {
+		app := newTestApplication()
+		app.writeAccessLog(r, res)
+	})
+}
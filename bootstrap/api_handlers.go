@@ -1,12 +1,18 @@
 package bootstrap
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/gorilla/websocket"
 	"github.com/ncastellani/partida/utilfunc"
 )
 
@@ -15,13 +21,13 @@ import (
 // Golang net/http default lib.
 func (app *Application) APIHTTPHandler(w http.ResponseWriter, e *http.Request) {
 
-	// get the request input body
-	input, _ := io.ReadAll(e.Body)
-
 	// get the IP from request
-	ip := strings.Split(e.RemoteAddr, ":")[0]
-	if strings.Contains(e.RemoteAddr, "[::1]") {
-		ip = "127.0.0.1"
+	ip := remoteIP(e)
+
+	// serve the built-in metrics route, unless it has its own listener
+	if s := app.MetricsSettings; s.Route != "" && s.Address == "" && e.URL.Path == "/"+s.Route {
+		app.serveMetricsRoute(w, e, ip)
+		return
 	}
 
 	// iterate over the headers to get the first value
@@ -44,17 +50,44 @@ func (app *Application) APIHTTPHandler(w http.ResponseWriter, e *http.Request) {
 
 	// assemble and perform the request validation and method
 	r := APIRequest{
-		ID:      utilfunc.RandomString(10),
+		ID:      app.determineRequestID(headers, ""),
+		Context: e.Context(),
 		IP:      ip,
 		Query:   queryParams,
 		Headers: headers,
 		Method:  e.Method,
 		Path:    path,
-		Input:   input,
+	}
+
+	// the request body is read by the pipeline, bounded by the resource limit
+	r.SetBody(e.Body)
+
+	// allow the streaming resources to write into the response
+	r.streamer = httpResponseStreamer{w}
+
+	// allow the WebSocket resources to upgrade the connection
+	if websocket.IsWebSocketUpgrade(e) {
+		r.connection = &WebSocketConnection{ID: NewRequestID()}
+		r.upgrade = func(header http.Header) (*websocket.Conn, error) {
+			upgrader := app.webSocketUpgrader(&r)
+			return upgrader.Upgrade(w, e, header)
+		}
 	}
 
 	res := app.handleAPIRequest(&r)
 
+	// the streamed responses were already written
+	if r.streamed {
+		r.Logger.Info("request answered with a stream", "code", r.Result.Code)
+
+		// serve the upgraded WebSocket connections until they are closed
+		if r.connection != nil && r.connection.ws != nil {
+			app.serveWebSocket(&r)
+		}
+
+		return
+	}
+
 	// append the request ID
 	if len(res.Headers) == 0 {
 		res.Headers = make(map[string]string)
@@ -62,26 +95,42 @@ func (app *Application) APIHTTPHandler(w http.ResponseWriter, e *http.Request) {
 
 	res.Headers["x-request-id"] = r.ID
 
-	// set the response headers on the request
+	// set the response headers and cookies on the request
 	for k, v := range res.Headers {
 		w.Header().Set(k, v)
 	}
 
+	for _, v := range res.Cookies {
+		w.Header().Add("Set-Cookie", v)
+	}
+
 	// return the response to the user
 	w.WriteHeader(res.HTTPCode)
 	w.Write(res.Content)
 
-	r.Logger.Println("DONE")
+	r.logAnswered(res)
+
+}
+
+// remoteIP
+// determine the IP address of the client of an HTTP request.
+func remoteIP(e *http.Request) string {
+	ip := strings.Split(e.RemoteAddr, ":")[0]
+	if strings.Contains(e.RemoteAddr, "[::1]") {
+		ip = "127.0.0.1"
+	}
 
+	return ip
 }
 
 // APILambdaHandler
 // handle an inbound AWS Lambda request
-func (app *Application) APILambdaHandler(e events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func (app *Application) APILambdaHandler(ctx context.Context, e events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
 	// generate the request with the relevant data
 	r := APIRequest{
-		ID:      e.RequestContext.RequestID,
+		ID:      app.determineRequestID(e.Headers, e.RequestContext.RequestID),
+		Context: ctx,
 		IP:      e.RequestContext.Identity.SourceIP,
 		Method:  e.RequestContext.HTTPMethod,
 		Query:   e.QueryStringParameters,
@@ -95,17 +144,16 @@ func (app *Application) APILambdaHandler(e events.APIGatewayProxyRequest) (event
 		r.Path = e.Path[1:]
 	}
 
-	// get the request input body also handling Base64 encoded bodies
-	if e.IsBase64Encoded {
-		r.Input, _ = base64.StdEncoding.DecodeString(e.Body)
-	} else {
-		r.Input = []byte(e.Body)
-	}
+	// set the request input body also handling Base64 encoded bodies
+	r.SetBody(lambdaRequestBody(e.Body, e.IsBase64Encoded))
 
 	// assemble and perform the request validation and method
 	res := app.handleAPIRequest(&r)
 
-	r.Logger.Println("DONE")
+	r.logAnswered(res)
+
+	app.emitRequestEMF(&r)
+	app.flushTracing(ctx)
 
 	// append the request ID
 	if len(res.Headers) == 0 {
@@ -114,24 +162,29 @@ func (app *Application) APILambdaHandler(e events.APIGatewayProxyRequest) (event
 
 	res.Headers["x-request-id"] = r.ID
 
+	body, isBase64 := lambdaResponseBody(res)
+
 	return events.APIGatewayProxyResponse{
-		StatusCode: res.HTTPCode,
-		Headers:    res.Headers,
-		Body:       string(res.Content),
+		StatusCode:        res.HTTPCode,
+		Headers:           res.Headers,
+		MultiValueHeaders: cookieHeaders(res.Cookies),
+		Body:              body,
+		IsBase64Encoded:   isBase64,
 	}, nil
 }
 
 // APILambdaV2Handler
 // handle an inbound AWS Lambda request (via API Gateway v2)
-func (app *Application) APILambdaV2Handler(e events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+func (app *Application) APILambdaV2Handler(ctx context.Context, e events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 
 	// generate the request with the relevant data
 	r := APIRequest{
-		ID:      e.RequestContext.RequestID,
+		ID:      app.determineRequestID(e.Headers, e.RequestContext.RequestID),
+		Context: ctx,
 		IP:      e.RequestContext.HTTP.SourceIP,
 		Method:  e.RequestContext.HTTP.Method,
 		Query:   e.QueryStringParameters,
-		Headers: e.Headers,
+		Headers: withCookieHeader(e.Headers, e.Cookies),
 	}
 
 	// parse the path for getting the action
@@ -141,17 +194,188 @@ func (app *Application) APILambdaV2Handler(e events.APIGatewayV2HTTPRequest) (ev
 		r.Path = e.RawPath[1:]
 	}
 
-	// get the request input body also handling Base64 encoded bodies
-	if e.IsBase64Encoded {
-		r.Input, _ = base64.StdEncoding.DecodeString(e.Body)
+	// set the request input body also handling Base64 encoded bodies
+	r.SetBody(lambdaRequestBody(e.Body, e.IsBase64Encoded))
+
+	// assemble and perform the request validation and method
+	res := app.handleAPIRequest(&r)
+
+	r.logAnswered(res)
+
+	app.emitRequestEMF(&r)
+	app.flushTracing(ctx)
+
+	// append the request ID
+	if len(res.Headers) == 0 {
+		res.Headers = make(map[string]string)
+	}
+
+	res.Headers["x-request-id"] = r.ID
+
+	body, isBase64 := lambdaResponseBody(res)
+
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode:      res.HTTPCode,
+		Headers:         res.Headers,
+		Cookies:         res.Cookies,
+		Body:            body,
+		IsBase64Encoded: isBase64,
+	}, nil
+}
+
+// lambdaResponseBody
+// return the response content as a Lambda response body, encoding the
+// binary contents (compressed, of a non-text type or not valid UTF-8) as Base64.
+func lambdaResponseBody(res APIResponse) (body string, isBase64 bool) {
+	if res.Headers["Content-Encoding"] != "" || !textContentType(res.Headers["Content-Type"]) || !utf8.Valid(res.Content) {
+		return base64.StdEncoding.EncodeToString(res.Content), true
+	}
+
+	return string(res.Content), false
+}
+
+// textContentType
+// check if the media type is textual (as text/*, JSON, XML and forms).
+// responses without a type are decided by their content.
+func textContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	media, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	media = strings.TrimSpace(media)
+
+	switch {
+	case strings.HasPrefix(media, "text/"),
+		strings.HasSuffix(media, "+json"),
+		strings.HasSuffix(media, "+xml"):
+		return true
+	}
+
+	return utilfunc.StringInSlice(media, []string{
+		"application/json", "application/xml", "application/javascript",
+		"application/x-www-form-urlencoded", "application/graphql",
+	})
+}
+
+// lambdaRequestBody
+// return a reader of a Lambda request body, decoding Base64 encoded bodies.
+func lambdaRequestBody(body string, isBase64 bool) io.Reader {
+	if isBase64 {
+		return base64.NewDecoder(base64.StdEncoding, strings.NewReader(body))
+	}
+
+	return strings.NewReader(body)
+}
+
+// APIALBHandler
+// handle an inbound AWS Lambda request (via an Application Load Balancer target
+// group), answering with multi-value headers when the target group uses them.
+func (app *Application) APIALBHandler(ctx context.Context, e events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	multiValue := e.MultiValueHeaders != nil
+
+	// the ALB forwards the query parameters without decoding them
+	headers, query := e.Headers, e.QueryStringParameters
+	if multiValue {
+		headers, query = firstValues(e.MultiValueHeaders), firstValues(e.MultiValueQueryStringParameters)
+	}
+
+	decoded := make(map[string]string)
+	for k, v := range query {
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			key = k
+		}
+
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			value = v
+		}
+
+		decoded[key] = value
+	}
+
+	// generate the request with the relevant data
+	r := APIRequest{
+		ID:      app.determineRequestID(headers, ""),
+		Context: ctx,
+		IP:      forwardedIP(headers),
+		Method:  e.HTTPMethod,
+		Query:   decoded,
+		Headers: headers,
+	}
+
+	// parse the path for getting the action
+	r.Path = "index"
+
+	if e.Path != "/" {
+		r.Path = e.Path[1:]
+	}
+
+	// set the request input body also handling Base64 encoded bodies
+	r.SetBody(lambdaRequestBody(e.Body, e.IsBase64Encoded))
+
+	// assemble and perform the request validation and method
+	res := app.handleAPIRequest(&r)
+
+	r.logAnswered(res)
+
+	app.emitRequestEMF(&r)
+	app.flushTracing(ctx)
+
+	// append the request ID
+	if len(res.Headers) == 0 {
+		res.Headers = make(map[string]string)
+	}
+
+	res.Headers["x-request-id"] = r.ID
+
+	body, isBase64 := lambdaResponseBody(res)
+
+	out := events.ALBTargetGroupResponse{
+		StatusCode:        res.HTTPCode,
+		StatusDescription: fmt.Sprintf("%v %v", res.HTTPCode, http.StatusText(res.HTTPCode)),
+		Body:              body,
+		IsBase64Encoded:   isBase64,
+	}
+
+	// answer with the headers mode of the target group
+	if multiValue {
+		out.MultiValueHeaders = cookieHeaders(res.Cookies)
+		if out.MultiValueHeaders == nil {
+			out.MultiValueHeaders = make(map[string][]string)
+		}
+
+		for k, v := range res.Headers {
+			out.MultiValueHeaders[k] = []string{v}
+		}
 	} else {
-		r.Input = []byte(e.Body)
+		out.Headers = res.Headers
+
+		if len(res.Cookies) > 0 {
+			if len(res.Cookies) > 1 {
+				r.Logger.Warn("only the first cookie can be set without the multi-value headers of the target group", "cookies", len(res.Cookies))
+			}
+
+			out.Headers["Set-Cookie"] = res.Cookies[0]
+		}
 	}
 
+	return out, nil
+}
+
+// APIFunctionURLHandler
+// handle an inbound AWS Lambda request (via a Lambda Function URL)
+func (app *Application) APIFunctionURLHandler(ctx context.Context, e events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+	r := app.functionURLRequest(ctx, e)
+
 	// assemble and perform the request validation and method
 	res := app.handleAPIRequest(&r)
 
-	r.Logger.Println("DONE")
+	r.logAnswered(res)
+
+	app.emitRequestEMF(&r)
+	app.flushTracing(ctx)
 
 	// append the request ID
 	if len(res.Headers) == 0 {
@@ -160,9 +384,188 @@ func (app *Application) APILambdaV2Handler(e events.APIGatewayV2HTTPRequest) (ev
 
 	res.Headers["x-request-id"] = r.ID
 
-	return events.APIGatewayV2HTTPResponse{
-		StatusCode: res.HTTPCode,
-		Headers:    res.Headers,
-		Body:       string(res.Content),
+	body, isBase64 := lambdaResponseBody(res)
+
+	return events.LambdaFunctionURLResponse{
+		StatusCode:      res.HTTPCode,
+		Headers:         res.Headers,
+		Cookies:         res.Cookies,
+		Body:            body,
+		IsBase64Encoded: isBase64,
 	}, nil
 }
+
+// APIFunctionURLStreamingHandler
+// handle an inbound AWS Lambda request (via a Lambda Function URL with the
+// RESPONSE_STREAM invoke mode), allowing the streaming resources to send
+// their events progressively. requires the "provided.al2" runtime or the
+// "lambda.norpc" build tag.
+func (app *Application) APIFunctionURLStreamingHandler(ctx context.Context, e events.LambdaFunctionURLRequest) (*events.LambdaFunctionURLStreamingResponse, error) {
+	r := app.functionURLRequest(ctx, e)
+
+	// the pipeline writes the streams into the response body while it is read
+	pr, pw := io.Pipe()
+
+	streamer := &lambdaResponseStreamer{w: pw, started: make(chan APIResponse, 1)}
+	r.streamer = streamer
+
+	answered := make(chan APIResponse, 1)
+
+	go func() {
+		res := app.handleAPIRequest(&r)
+
+		r.logAnswered(res, "streamed", r.streamed)
+
+		app.emitRequestEMF(&r)
+		app.flushTracing(ctx)
+
+		// end the invocation by closing the streamed body
+		if r.streamed {
+			pw.Close()
+			return
+		}
+
+		answered <- res
+	}()
+
+	select {
+	case res := <-streamer.started:
+
+		// the stream headers are kept by the request, answer with a copy of them
+		headers := map[string]string{"x-request-id": r.ID}
+		for k, v := range res.Headers {
+			headers[k] = v
+		}
+
+		return &events.LambdaFunctionURLStreamingResponse{
+			StatusCode: res.HTTPCode,
+			Headers:    headers,
+			Cookies:    res.Cookies,
+			Body:       pr,
+		}, nil
+	case res := <-answered:
+		if len(res.Headers) == 0 {
+			res.Headers = make(map[string]string)
+		}
+
+		res.Headers["x-request-id"] = r.ID
+
+		return &events.LambdaFunctionURLStreamingResponse{
+			StatusCode: res.HTTPCode,
+			Headers:    res.Headers,
+			Cookies:    res.Cookies,
+			Body:       bytes.NewReader(res.Content),
+		}, nil
+	}
+}
+
+// functionURLRequest
+// generate the request of a Lambda Function URL event.
+func (app *Application) functionURLRequest(ctx context.Context, e events.LambdaFunctionURLRequest) APIRequest {
+	r := APIRequest{
+		ID:      app.determineRequestID(e.Headers, e.RequestContext.RequestID),
+		Context: ctx,
+		IP:      e.RequestContext.HTTP.SourceIP,
+		Method:  e.RequestContext.HTTP.Method,
+		Query:   e.QueryStringParameters,
+		Headers: withCookieHeader(e.Headers, e.Cookies),
+	}
+
+	// parse the path for getting the action
+	r.Path = "index"
+
+	if e.RawPath != "/" && e.RawPath != "" {
+		r.Path = e.RawPath[1:]
+	}
+
+	// set the request input body also handling Base64 encoded bodies
+	r.SetBody(lambdaRequestBody(e.Body, e.IsBase64Encoded))
+
+	return r
+}
+
+// lambdaResponseStreamer
+// stream a response into the body of a Lambda streaming response.
+type lambdaResponseStreamer struct {
+	w       *io.PipeWriter
+	started chan APIResponse
+}
+
+// start
+// hand the status code, headers and cookies to the Lambda handler.
+func (s *lambdaResponseStreamer) start(code int, headers map[string]string, cookies []string) {
+	s.started <- APIResponse{HTTPCode: code, Headers: headers, Cookies: cookies}
+}
+
+// Write
+// write data into the response body, blocking until it is read.
+func (s *lambdaResponseStreamer) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+// flush
+// do nothing, as the written data is read right away.
+func (s *lambdaResponseStreamer) flush() error {
+	return nil
+}
+
+// firstValues
+// return the first value of each key of a multi-value map.
+func firstValues(values map[string][]string) map[string]string {
+	first := make(map[string]string)
+	for k, v := range values {
+		if len(v) > 0 {
+			first[k] = v[0]
+		}
+	}
+
+	return first
+}
+
+// withCookieHeader
+// return the headers with the cookies that Lambda events pass apart
+// joined back into the "Cookie" header.
+func withCookieHeader(headers map[string]string, cookies []string) map[string]string {
+	if len(cookies) == 0 {
+		return headers
+	}
+
+	joined := make(map[string]string)
+	for k, v := range headers {
+		joined[k] = v
+	}
+
+	joined["cookie"] = strings.Join(cookies, "; ")
+
+	return joined
+}
+
+// cookieHeaders
+// return the multi-value "Set-Cookie" headers of the response cookies.
+func cookieHeaders(cookies []string) map[string][]string {
+	if len(cookies) == 0 {
+		return nil
+	}
+
+	return map[string][]string{"Set-Cookie": cookies}
+}
+
+// logAnswered
+// log the answered request, except for the built-in health routes.
+func (r *APIRequest) logAnswered(res APIResponse, args ...any) {
+	if r.probe {
+		return
+	}
+
+	r.Logger.Info("request answered", append([]any{"status", res.HTTPCode, "code", r.Result.Code}, args...)...)
+}
+
+// forwardedIP
+// return the client IP address from the "X-Forwarded-For" header set by the load balancer.
+// the load balancer appends the address it was connected from, so the rightmost entry
+// is used, as the others are sent by the client and can be spoofed.
+func forwardedIP(headers map[string]string) string {
+	v := headersCarrier(headers).Get("X-Forwarded-For")
+
+	return strings.TrimSpace(v[strings.LastIndex(v, ",")+1:])
+}
@@ -0,0 +1,107 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestForwardedIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		ip      string
+	}{
+		{"no header", map[string]string{}, ""},
+		{"single address", map[string]string{"X-Forwarded-For": "203.0.113.7"}, "203.0.113.7"},
+		{"address spoofed by the client", map[string]string{"x-forwarded-for": "10.0.0.1, 203.0.113.7"}, "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ip := forwardedIP(tt.headers); ip != tt.ip {
+				t.Errorf("forwardedIP() = %q, want %q", ip, tt.ip)
+			}
+		})
+	}
+}
+
+func TestLambdaResponseBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		content []byte
+		base64  bool
+	}{
+		{"JSON", map[string]string{"Content-Type": "application/json; charset=utf-8"}, []byte(`{"a":1}`), false},
+		{"XML", map[string]string{"Content-Type": "application/xml"}, []byte("<a/>"), false},
+		{"text", map[string]string{"Content-Type": "text/event-stream"}, []byte("data: a\n\n"), false},
+		{"structured syntax suffix", map[string]string{"Content-Type": "application/problem+json"}, []byte("{}"), false},
+		{"compressed", map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"}, []byte{0x1f, 0x8b}, true},
+		{"image", map[string]string{"Content-Type": "image/png"}, []byte{0x89, 'P', 'N', 'G'}, true},
+		{"octet stream with a text content", map[string]string{"Content-Type": "application/octet-stream"}, []byte("abc"), true},
+		{"no type with a text content", map[string]string{}, []byte("abc"), false},
+		{"text type with an invalid UTF-8 content", map[string]string{"Content-Type": "text/plain"}, []byte{0xff, 0xfe}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, isBase64 := lambdaResponseBody(APIResponse{Headers: tt.headers, Content: tt.content})
+
+			if isBase64 != tt.base64 {
+				t.Fatalf("isBase64 = %v, want %v", isBase64, tt.base64)
+			}
+
+			decoded := []byte(body)
+			if isBase64 {
+				decoded, _ = base64.StdEncoding.DecodeString(body)
+			}
+
+			if !bytes.Equal(decoded, tt.content) {
+				t.Errorf("body = %q, want %q", decoded, tt.content)
+			}
+		})
+	}
+}
+
+func TestFunctionURLStreamingHandler(t *testing.T) {
+	app := newTestApplication()
+
+	app.Route("events").Stream(func(r *APIRequest, s *EventStream) Result {
+		r.SetCookie(&http.Cookie{Name: "session", Value: "1"})
+		s.Send(Event{Data: "hello"})
+
+		return Result{Code: "OK"}
+	})
+
+	e := events.LambdaFunctionURLRequest{RawPath: "/events"}
+	e.RequestContext.HTTP.Method = "GET"
+	e.RequestContext.RequestID = "req-1"
+
+	res, err := app.APIFunctionURLStreamingHandler(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != http.StatusOK || string(body) != "data: hello\n\n" {
+		t.Errorf("response = %v %q, want %v the streamed event", res.StatusCode, body, http.StatusOK)
+	}
+
+	if res.Headers["x-request-id"] != "req-1" {
+		t.Errorf("x-request-id = %q, want req-1", res.Headers["x-request-id"])
+	}
+
+	if len(res.Cookies) != 1 || res.Cookies[0] != "session=1" {
+		t.Errorf("cookies = %v, want [session=1]", res.Cookies)
+	}
+}
@@ -0,0 +1,182 @@
+package bootstrap
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// APIHandler
+// define a step of the API request pipeline. it operates over the
+// request, reading and updating its result (and response, when assembled).
+type APIHandler func(r *APIRequest)
+
+// APIMiddleware
+// define a function that wraps the next handler of the API pipeline.
+// it can act before and after calling next, or not call it at all
+// to short-circuit the pipeline with the current request result.
+type APIMiddleware func(next APIHandler) APIHandler
+
+// max request body size of the applications that do not define one
+const defaultMaxBodySize int64 = 10 << 20
+
+// chainAPIMiddlewares
+// wrap the final handler with the passed middlewares. the first
+// middleware on the list will be the outermost one of the chain.
+func chainAPIMiddlewares(final APIHandler, mws ...APIMiddleware) APIHandler {
+	h := final
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+
+	return h
+}
+
+// apiOperator
+// express a request operator as a built-in middleware. the operator is
+// executed within its own span and the pipeline only continues if the
+// result is still OK.
+func apiOperator(stage string, op func(r *APIRequest)) APIMiddleware {
+	return func(next APIHandler) APIHandler {
+		return func(r *APIRequest) {
+			parent := r.Context
+
+			ctx, span := startSpan(parent, "partida."+stage)
+			r.Context = ctx
+
+			started := time.Now()
+
+			op(r)
+
+			r.stages = append(r.stages, stageTiming{stage, time.Since(started)})
+
+			span.SetAttributes(attribute.String("partida.code", r.Result.Code))
+			span.End()
+
+			r.Context = parent
+
+			if r.Result.Code != "OK" {
+				r.Logger.Debug("pipeline interrupted by a non-OK result", "stage", stage, "code", r.Result.Code)
+				return
+			}
+
+			next(r)
+		}
+	}
+}
+
+// apiPipeline
+// return the full list of middlewares of the API pipeline: the application
+// ones wrapping the built-in response assembly and the request operators.
+func (app *Application) apiPipeline() []APIMiddleware {
+	builtin := []APIMiddleware{
+		app.assembleResponse,
+		recoverOperators,
+		apiOperator("content_type", func(r *APIRequest) { r.determineAcceptedContentType() }),
+		apiOperator("routing", func(r *APIRequest) {
+			routes := r.configuration(app).routes
+			r.determineResource(&routes)
+		}),
+		apiOperator("network", func(r *APIRequest) { r.verifyNetwork() }),
+		apiOperator("auth_token", func(r *APIRequest) { r.extractAuthorizationToken() }),
+		apiOperator("authorization", func(r *APIRequest) { r.authorizeUser(&app.Backend) }),
+		apiOperator("body", func(r *APIRequest) { r.readBody(app.maxBodySize(r)) }),
+		app.idempotency,
+		apiOperator("payload", func(r *APIRequest) { r.parsePayload() }),
+		apiOperator("validators", func(r *APIRequest) { r.validateResourceParameters(&app.APIValidators) }),
+		apiOperator("pagination", func(r *APIRequest) { r.parsePagination(&app.PaginationSettings) }),
+		apiOperator("fields", func(r *APIRequest) { r.parseFieldSelection() }),
+		apiOperator("before_method", func(r *APIRequest) { r.callBackendPreExecution(&app.Backend) }),
+		app.routeMiddlewares,
+		apiOperator("method", func(r *APIRequest) {
+			switch {
+			case r.Resource.WebSocket:
+				app.callWebSocketConnect(r)
+			case r.Resource.Stream:
+				app.callStreamMethod(r)
+			default:
+				r.callMethod(&app.APIMethods)
+			}
+		}),
+		apiOperator("after_method", func(r *APIRequest) { r.callBackendPostExecution(&app.Backend) }),
+		apiOperator("select_fields", func(r *APIRequest) { r.selectFields() }),
+	}
+
+	return append(append([]APIMiddleware{}, app.APIMiddlewares...), builtin...)
+}
+
+// assembleResponse
+// call the rest of the pipeline and then generate the
+// HTTP response for the request result that was obtained.
+func (app *Application) assembleResponse(next APIHandler) APIHandler {
+	return func(r *APIRequest) {
+		next(r)
+
+		r.Logger.Debug("finished the request handler job")
+
+		// keep the response assembled by an inner middleware (as replays)
+		if r.Response.HTTPCode != 0 {
+			return
+		}
+
+		r.Response = r.makeResponse(app)
+	}
+}
+
+// recoverOperators
+// handle panics at the request operators and middlewares calls.
+func recoverOperators(next APIHandler) APIHandler {
+	return func(r *APIRequest) {
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				r.Result = r.recoverResult(rcv)
+			}
+		}()
+
+		next(r)
+	}
+}
+
+// routeMiddlewares
+// wrap the remaining pipeline with the middlewares declared
+// at the resource, fetching them by name from the application.
+func (app *Application) routeMiddlewares(next APIHandler) APIHandler {
+	return func(r *APIRequest) {
+		if len(r.Resource.Middlewares) == 0 {
+			next(r)
+			return
+		}
+
+		var mws []APIMiddleware
+
+		for _, name := range r.Resource.Middlewares {
+			mw, ok := app.APIRouteMiddlewares[name]
+			if !ok {
+				r.Logger.Error("resource middleware does not exists at the middlewares map", "middleware", name)
+
+				r.updateResult("GEN-0014", name)
+				return
+			}
+
+			mws = append(mws, mw)
+		}
+
+		r.Logger.Debug("calling the resource middlewares", "count", len(mws))
+
+		chainAPIMiddlewares(next, mws...)(r)
+	}
+}
+
+// maxBodySize
+// return the max request body size of the resource or of the application.
+func (app *Application) maxBodySize(r *APIRequest) int64 {
+	if r.Resource.MaxBodySize > 0 {
+		return r.Resource.MaxBodySize
+	}
+
+	if app.MaxBodySize > 0 {
+		return app.MaxBodySize
+	}
+
+	return defaultMaxBodySize
+}
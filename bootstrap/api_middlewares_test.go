@@ -0,0 +1,129 @@
+package bootstrap
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIPipelineMiddlewares(t *testing.T) {
+	app := newTestApplication()
+
+	var calls []string
+
+	trace := func(name string) APIMiddleware {
+		return func(next APIHandler) APIHandler {
+			return func(r *APIRequest) {
+				calls = append(calls, name+":in")
+				next(r)
+				calls = append(calls, name+":out")
+			}
+		}
+	}
+
+	app.APIMiddlewares = []APIMiddleware{trace("outer"), trace("inner")}
+	app.APIRouteMiddlewares = map[string]APIMiddleware{"route": trace("route")}
+
+	app.APIRoutes["hello"] = map[string]APIResource{"GET": {ResourceMethod: "hello", Middlewares: []string{"route"}}}
+	app.APIMethods["hello"] = func(r *APIRequest) Result {
+		calls = append(calls, "method")
+		return Result{"OK", "hi"}
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		code   string
+		calls  []string
+	}{
+		{"route with middlewares", "/hello", "OK", []string{"outer:in", "inner:in", "route:in", "method", "route:out", "inner:out", "outer:out"}},
+		{"unknown route skips the route middlewares", "/nope", "GEN-0004", []string{"outer:in", "inner:in", "inner:out", "outer:out"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+
+			if res := serveTestRequest(t, app, "GET", tt.target, "", nil); res.Code != tt.code {
+				t.Fatalf("code = %v, want %v", res.Code, tt.code)
+			}
+
+			if strings.Join(calls, ",") != strings.Join(tt.calls, ",") {
+				t.Errorf("calls = %v, want %v", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestCallMethodOutcomes(t *testing.T) {
+	app := newTestApplication()
+
+	// a method that ignores its context and keeps using the request after its timeout
+	late := make(chan struct{})
+
+	app.APIMethods["late"] = func(r *APIRequest) Result {
+		time.Sleep(50 * time.Millisecond)
+
+		r.SetCookie(&http.Cookie{Name: "late", Value: "1"})
+		r.Headers["X-Late"] = "1"
+		r.Query["late"] = "1"
+		(*r.Parameters)["q"] = "late"
+		r.SetNextCursor("late")
+		r.LastModified = time.Now()
+		r.Logger = r.Logger.With("late", true)
+		r.Result = Result{"OK", "late"}
+
+		close(late)
+
+		return r.Result
+	}
+
+	app.APIMethods["empty"] = func(r *APIRequest) Result { return Result{Data: "empty"} }
+	app.APIMethods["panic"] = func(r *APIRequest) Result { panic("method failure") }
+	app.APIMethods["cookie"] = func(r *APIRequest) Result {
+		r.SetCookie(&http.Cookie{Name: "session", Value: "1"})
+		return Result{"OK", "cookie"}
+	}
+
+	app.APIRoutes["late"] = map[string]APIResource{"GET": {
+		ResourceMethod: "late",
+		Timeout:        10,
+		Parameters:     []APIResourceParameter{{Name: "q", Kind: "string", QueryParameter: true}},
+	}}
+	app.APIRoutes["empty"] = map[string]APIResource{"GET": {ResourceMethod: "empty"}}
+	app.APIRoutes["panic"] = map[string]APIResource{"GET": {ResourceMethod: "panic"}}
+	app.APIRoutes["cookie"] = map[string]APIResource{"GET": {ResourceMethod: "cookie"}}
+	app.APIRoutes["missing"] = map[string]APIResource{"GET": {ResourceMethod: "missing"}}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		code   string
+		cookie string
+	}{
+		{"empty code answered as OK", "/empty", http.StatusOK, "OK", ""},
+		{"cookies merged from the method", "/cookie", http.StatusOK, "OK", "session=1"},
+		{"panic answered as an incident", "/panic", http.StatusInternalServerError, "SE", ""},
+		{"timeout discards the late changes", "/late?q=1", http.StatusGatewayTimeout, "GEN-0015", ""},
+		{"function not registered", "/missing", http.StatusNotImplemented, "GEN-0001", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serveTestRequest(t, app, "GET", tt.target, "", nil)
+
+			if res.Status != tt.status || res.Code != tt.code {
+				t.Errorf("response = %v %v, want %v %v", res.Status, res.Code, tt.status, tt.code)
+			}
+
+			if res.Headers["Set-Cookie"] != tt.cookie {
+				t.Errorf("Set-Cookie = %q, want %q", res.Headers["Set-Cookie"], tt.cookie)
+			}
+		})
+	}
+
+	// wait for the late method, letting the race detector check its writes
+	<-late
+}
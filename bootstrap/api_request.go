@@ -1,115 +1,235 @@
 package bootstrap
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"maps"
 	"net"
+	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 
+	"github.com/gorilla/websocket"
 	"github.com/ncastellani/partida/utilfunc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // APIRequest
 // define an incoming request, with its metadata, payload and useful contents.
 type APIRequest struct {
-	ID          string      // request identifier
-	ContentType string      // format of the response (json or xml) default = json
-	Logger      *log.Logger // general request logging
+	ID          string          // request identifier
+	Context     context.Context // request context (cancelled on client disconnect, deadline or timeout)
+	ContentType string          // format of the response (json or xml) default = json
+	Logger      *slog.Logger    // request logger (with the request ID, path, method, IP and user)
 
 	IP      string            // request initiator IP address
 	Query   map[string]string // GET method query parameters
 	Headers map[string]string // request HTTP headers
 	Path    string            // requested path
 	Method  string            // HTTP request verb
-	Input   []byte            // input data
+	Input   []byte            // input data (read by the pipeline before the payload parse)
+	body    io.Reader         // body reader set by the adapters, read into the input data
 
 	ExtractedToken string                  // token fetched from the Authorization header
 	Parameters     *map[string]interface{} // parsed parameters
 	Resource       APIResource             // resource data
 	Result         Result                  // resource handler result
+	LastModified   time.Time               // modification time of the result data (set by the method) for conditional requests
+	Response       APIResponse             // response assembled from the result
 
 	// backend data
 	Token interface{}
 	User  interface{}
+
+	// debug mode of the application (see Application.DebugMode)
+	debug bool
+
+	// if the request is a sub-request of a batch
+	batched bool
+
+	// if the request was answered by a built-in health route
+	probe bool
+
+	// closed once the resource method abandoned by a timeout or cancellation returns
+	abandoned <-chan struct{}
+
+	// pagination of the list resources
+	page         Page
+	nextCursor   string
+	cursorSecret []byte
+
+	// fields selected by the "fields" query parameter
+	fields fieldSelection
+
+	// response streamer of the adapter (nil if it can not stream) and
+	// if the response was streamed by the resource method
+	streamer responseStreamer
+	streamed bool
+
+	// cookies set into the response
+	cookies []string
+
+	// WebSocket connection being accepted and its net/http upgrade
+	connection *WebSocketConnection
+	upgrade    func(header http.Header) (*websocket.Conn, error)
+
+	// codes and routes used by the request (see configuration)
+	config *configSnapshot
+
+	// pipeline measurements
+	stages   []stageTiming
+	duration time.Duration
 }
 
 // take an pre-assembled request from an API handler
 // and call the request functions to perform the desired
 // operation and generate an response back to the handler.
-func (app *Application) handleAPIRequest(r *APIRequest) APIResponse {
+func (app *Application) handleAPIRequest(r *APIRequest) (res APIResponse) {
+	started := time.Now()
+
+	// take the configuration used by the whole request
+	r.configuration(app)
 
 	// generate the logger for this request
-	r.Logger = log.New(app.APILogsWriter, fmt.Sprintf("[%v]%v > ", r.ID, r.Path), log.LstdFlags|log.Lmsgprefix)
+	r.Logger = app.LogSettings.NewLogger(app.APILogsWriter).With(
+		slog.String("request_id", r.ID),
+		slog.String("path", r.Path),
+		slog.String("method", r.Method),
+		slog.String("ip", r.IP),
+	)
+
+	// answer the built-in health routes out of the pipeline and access logs
+	if res, ok := app.serveHealthRoute(r); ok {
+		r.probe = true
+		return res
+	}
 
-	r.Logger.Printf("request recieved [method: %v] [ip: %v]", r.Method, r.IP)
+	r.Logger.Info("request recieved")
 
-	// set the default contentType
+	// set the default contentType and the debug mode
 	r.ContentType = "json"
+	r.debug = app.DebugMode
+
+	// use a background context if the handler did not provide one
+	if r.Context == nil {
+		r.Context = context.Background()
+	}
+
+	r.Context = contextWithRequestID(r.Context, r.ID)
+
+	// start the request span as a child of the trace from the headers
+	var span trace.Span
+
+	r.Context = otel.GetTextMapPropagator().Extract(r.Context, headersCarrier(r.Headers))
+	r.Context, span = startSpan(r.Context, fmt.Sprintf("%v /%v", r.Method, r.Path),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath("/"+r.Path),
+			semconv.ClientAddress(r.IP),
+			attribute.String("partida.request_id", r.ID),
+		),
+	)
 
-	// handle panic at request operators calls
 	defer func() {
-		if rcv := recover(); rcv != nil {
-			r.Logger.Printf("request operator got in panic [err: %v]", rcv)
+		r.duration = time.Since(started)
+		app.recordRequestMetrics(r)
+		app.writeAccessLog(r, res)
+
+		span.SetAttributes(
+			semconv.HTTPResponseStatusCode(res.HTTPCode),
+			attribute.String("partida.code", r.Result.Code),
+		)
 
-			r.Result = Result{"SE", rcv}
+		if res.HTTPCode >= 500 {
+			span.SetStatus(codes.Error, r.Result.Code)
 		}
+
+		span.End()
 	}()
 
 	// set the request result as OK
 	r.Result = Result{Code: "OK", Data: utilfunc.Empty}
 
-	// call the request operators
-	r.determineAcceptedContentType()
-	r.determineResource(&app.APIRoutes)
-	r.verifyNetwork()
-	r.extractAuthorizationToken()
-	r.authorizeUser(&app.Backend)
-	r.parsePayload()
-	r.validateResourceParameters(&app.APIValidators)
-	r.callBackendPreExecution(&app.Backend)
-	r.callMethod(&app.APIMethods)
-	r.callBackendPostExecution(&app.Backend)
-
-	r.Logger.Printf("finished the request handler job")
-
-	return r.makeResponse(app)
+	// handle panic at the application middlewares calls
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			r.Result = r.recoverResult(rcv)
+			res = r.makeResponse(app)
+		}
+	}()
+
+	// call the middlewares chain with the request operators
+	chainAPIMiddlewares(func(r *APIRequest) {}, app.apiPipeline()...)(r)
+
+	// assemble the response if a middleware short-circuited before it
+	if r.Response.HTTPCode == 0 {
+		r.Response = r.makeResponse(app)
+	}
+
+	// compress the content with the encoding accepted by the client
+	app.compressResponse(r, &r.Response)
+
+	return r.Response
 }
 
 // return an HTTP response for the current request result.
 func (r *APIRequest) makeResponse(app *Application) APIResponse {
 	var err error
 
-	r.Logger.Printf("starting the response assemble... [code: %v]", r.Result.Code)
+	r.Logger.Debug("starting the response assemble...", "code", r.Result.Code)
+
+	// keep the internal failures details at the server side
+	r.concealInternalData()
+
+	// set the CACHE and content type headers
+	var headers map[string]string = map[string]string{
+		"Content-Type":  "application/json; charset=utf-8",
+		"Cache-Control": defaultCacheControl,
+	}
+
+	// set the resource caching headers and answer valid conditional requests
+	if r.setCacheHeaders(headers) {
+		r.Logger.Debug("the client representation was not modified")
+
+		r.updateResult("GEN-0017", utilfunc.Empty)
+	}
 
 	// check if the response code exists and fetch its data
-	code := app.Codes["GEN-0002"]
+	appCodes := r.configuration(app).codes
+	code := appCodes["GEN-0002"]
 
-	if v, ok := app.Codes[r.Result.Code]; ok {
+	if v, ok := appCodes[r.Result.Code]; ok {
 		code = v
 	}
 
-	// set the CORS, CACHE and content type headers
-	var headers map[string]string = map[string]string{
-		"Content-Type":                 "application/json; charset=utf-8",
-		"Cache-Control":                "max-age=0,private,must-revalidate,no-cache",
-		"Access-Control-Allow-Origin":  "*",
-		"Access-Control-Allow-Methods": "*",
-		"Access-Control-Allow-Headers": "*",
-		"Access-Control-Max-Age":       "86400",
-	}
+	// set the links to the next pages of the list resources
+	r.setPaginationHeaders(headers)
+
+	// set the CORS headers for the request origin
+	app.setCORSHeaders(r, headers)
 
 	if r.ContentType == "xml" {
-		r.Logger.Println("this response will be returned as XML")
+		r.Logger.Debug("this response will be returned as XML")
 
 		headers["Content-Type"] = "application/xml"
 	}
 
 	// assemble the request response with the code and provided data
+	nextCursor, hasMore := r.paginationMeta()
+
 	response := struct {
 		XMLName xml.Name    `json:"-"`
 		Meta    APIMetadata `json:"meta" xml:"meta"`
@@ -118,10 +238,12 @@ func (r *APIRequest) makeResponse(app *Application) APIResponse {
 		XMLName: xml.Name{Local: "response"},
 		Data:    r.Result.Data,
 		Meta: APIMetadata{
-			ID:      r.ID,
-			Time:    time.Now(),
-			Code:    r.Result.Code,
-			Message: code.Message,
+			ID:         r.ID,
+			Time:       time.Now(),
+			Code:       r.Result.Code,
+			Message:    code.Message,
+			NextCursor: nextCursor,
+			HasMore:    hasMore,
 		},
 	}
 
@@ -135,14 +257,27 @@ func (r *APIRequest) makeResponse(app *Application) APIResponse {
 	}
 
 	if err != nil {
-		r.Logger.Fatalf("failed to marshal JSON/XML with response [err: %v]", err)
+		r.Logger.Error("failed to marshal JSON/XML with response", "err", err)
+
+		return APIResponse{HTTPCode: appCodes["GEN-0003"].HTTPCode, Content: []byte{}, Headers: nil}
+	}
 
-		return APIResponse{HTTPCode: app.Codes["GEN-0003"].HTTPCode, Content: []byte{}, Headers: nil}
+	// answer the responses that can not hold a body without content
+	if code.HTTPCode == http.StatusNoContent || code.HTTPCode == http.StatusNotModified {
+		content = []byte{}
 	}
 
-	r.Logger.Println("API response assembled. returning HTTP response...")
+	r.Logger.Debug("API response assembled. returning HTTP response...")
+
+	return APIResponse{HTTPCode: code.HTTPCode, Content: content, Headers: headers, Cookies: r.cookies}
+}
 
-	return APIResponse{HTTPCode: code.HTTPCode, Content: content, Headers: headers}
+// SetCookie
+// add a cookie into the response. invalid cookies are dropped.
+func (r *APIRequest) SetCookie(c *http.Cookie) {
+	if v := c.String(); v != "" {
+		r.cookies = append(r.cookies, v)
+	}
 }
 
 // update the request result.
@@ -155,7 +290,7 @@ func (r *APIRequest) updateResult(code string, data interface{}) {
 func (r *APIRequest) determineAcceptedContentType() {
 	if val, ok := r.Headers["Accept"]; ok {
 		if val == "application/xml" && r.ContentType == "" {
-			r.Logger.Println("application/xml content type found on \"Accept\" header")
+			r.Logger.Debug("application/xml content type found on \"Accept\" header")
 
 			r.ContentType = "xml"
 		}
@@ -171,21 +306,26 @@ func (r *APIRequest) determineResource(routes *map[string]map[string]APIResource
 
 	// check for route existence at the controller
 	if _, ok := (*routes)[r.Path]; !ok {
-		r.Logger.Printf("route not found [path: %v]", r.Path)
+		r.Logger.Info("route not found")
 
 		r.updateResult("GEN-0004", utilfunc.Empty)
 		return
 	}
 
-	r.Logger.Printf("route exists. checking HTTP method for a resource... [route: %v]", r.Path)
+	r.Logger.Debug("route exists. checking HTTP method for a resource...")
 
 	// check for route methods
 	if v, ok := (*routes)[r.Path][r.Method]; !ok {
-		r.Logger.Printf("method not available for this route [method: %v]", r.Method)
+		r.Logger.Info("method not available for this route")
 
-		// return an OK response for OPTIONS verb validations
+		// answer OPTIONS verb validations as CORS preflights, using the
+		// resource of the requested method for its cross-origin policy
 		if r.Method == "OPTIONS" {
-			r.Logger.Printf("the current request is an OPTIONS check validation")
+			r.Logger.Debug("the current request is an OPTIONS check validation")
+
+			if v, ok := (*routes)[r.Path][headersCarrier(r.Headers).Get("Access-Control-Request-Method")]; ok {
+				r.Resource = v
+			}
 
 			r.updateResult("GEN-0005", utilfunc.Empty)
 			return
@@ -197,7 +337,7 @@ func (r *APIRequest) determineResource(routes *map[string]map[string]APIResource
 		r.Resource = v
 	}
 
-	r.Logger.Printf("resource exists. a valid HTTP method was used at this route, matching a resource [method: %v]", r.Method)
+	r.Logger.Debug("resource exists. a valid HTTP method was used at this route, matching a resource", "function", r.Resource.ResourceMethod)
 
 }
 
@@ -208,24 +348,30 @@ func (r *APIRequest) verifyNetwork() {
 	}
 
 	// check if the current IP address pass the network policy
+	if !r.Resource.Network.allows(r.IP) {
+		r.Logger.Info("resource does not allow this user IP")
+
+		r.updateResult("GEN-0007", utilfunc.Empty)
+		return
+	}
+
+	r.Logger.Debug("resource supports the network data of this user")
+
+}
+
+// allows
+// return if the network policy accepts the IP address.
+func (n APIResourceNetwork) allows(ip string) bool {
 	addrInExceptions := false
-	for _, v := range r.Resource.Network.Exceptions {
+	for _, v := range n.Exceptions {
 		_, IPrange, _ := net.ParseCIDR(v)
-		userAddr := net.ParseIP(r.IP)
+		userAddr := net.ParseIP(ip)
 		if !addrInExceptions {
 			addrInExceptions = IPrange.Contains(userAddr)
 		}
 	}
 
-	if (r.Resource.Network.Default == "deny" && !addrInExceptions) || (r.Resource.Network.Default == "allow" && addrInExceptions) {
-		r.Logger.Printf("resource does not allow this user IP [ip: %v]", r.IP)
-
-		r.updateResult("GEN-0007", utilfunc.Empty)
-		return
-	}
-
-	r.Logger.Printf("resource supports the network data of this user [ip: %v]", r.IP)
-
+	return !((n.Default == "deny" && !addrInExceptions) || (n.Default == "allow" && addrInExceptions))
 }
 
 // get the passed user token from the Authorization header.
@@ -234,7 +380,7 @@ func (r *APIRequest) extractAuthorizationToken() {
 		return
 	}
 
-	r.Logger.Println("trying to fetch auth token from the 'Authorization' header...")
+	r.Logger.Debug("trying to fetch auth token from the 'Authorization' header...")
 
 	// check if the header Authorization was passed
 	var token string
@@ -242,7 +388,7 @@ func (r *APIRequest) extractAuthorizationToken() {
 	if v, ok := r.Headers["Authorization"]; ok {
 		token = v
 	} else {
-		r.Logger.Println("'Authorization' header is not present or does not holds any content")
+		r.Logger.Info("'Authorization' header is not present or does not holds any content")
 
 		r.updateResult("GEN-0008", utilfunc.Empty)
 		return
@@ -252,7 +398,7 @@ func (r *APIRequest) extractAuthorizationToken() {
 	authHeader := strings.Fields(token)
 
 	if len(authHeader) == 1 {
-		r.Logger.Println("the \"Authorization\" header is present but does not use the correct format")
+		r.Logger.Info("the \"Authorization\" header is present but does not use the correct format")
 
 		r.updateResult("GEN-0009", utilfunc.Empty)
 		return
@@ -260,7 +406,7 @@ func (r *APIRequest) extractAuthorizationToken() {
 
 	r.ExtractedToken = authHeader[1]
 
-	r.Logger.Printf("sucessfully obtained the authorization token [token: ...%v]", r.ExtractedToken[len(r.ExtractedToken)-4:])
+	r.Logger.Debug("sucessfully obtained the authorization token", "token", "..."+r.ExtractedToken[len(r.ExtractedToken)-4:])
 
 }
 
@@ -270,10 +416,61 @@ func (r *APIRequest) authorizeUser(be *Backend) {
 		return
 	}
 
-	r.Logger.Println("calling the user authorizer at the backend service...")
+	r.Logger.Debug("calling the user authorizer at the backend service...")
 
 	r.Result = (*be).APIAuthorizeUser(r)
 
+	// append the authorized user into the request logger
+	if attr, ok := userLogAttr(r.User); ok {
+		r.Logger = r.Logger.With(attr)
+	}
+
+}
+
+// SetBody
+// set the reader of the request body. the pipeline reads it into
+// the input data, bounded by the resource max body size.
+func (r *APIRequest) SetBody(body io.Reader) {
+	r.body = body
+}
+
+// read the request body into the input data, limited to the max size.
+func (r *APIRequest) readBody(maxSize int64) {
+	if r.Result.Code != "OK" || r.body == nil {
+		return
+	}
+
+	// reject bodies that declare a length above the limit
+	if v := headersCarrier(r.Headers).Get("Content-Length"); v != "" {
+		if length, err := strconv.ParseInt(v, 10, 64); err == nil && length > maxSize {
+			r.Logger.Info("request body length is above the limit", "length", length, "max_size", maxSize)
+
+			r.updateResult("GEN-0018", maxSize)
+			return
+		}
+	}
+
+	// read up to one byte above the limit to detect larger bodies
+	input, err := io.ReadAll(io.LimitReader(r.body, maxSize+1))
+	if err != nil {
+		r.Logger.Info("failed to read the request body", "err", err, "read", len(input))
+
+		r.updateResult("GEN-0019", utilfunc.Empty)
+		return
+	}
+
+	if int64(len(input)) > maxSize {
+		r.Logger.Info("request body is above the limit", "max_size", maxSize)
+
+		r.updateResult("GEN-0018", maxSize)
+		return
+	}
+
+	r.Input = input
+	r.body = nil
+
+	r.Logger.Debug("read the request body", "size", len(r.Input))
+
 }
 
 // extract and parse parameters from URL query and body payload.
@@ -282,7 +479,7 @@ func (r *APIRequest) parsePayload() {
 		return
 	}
 
-	r.Logger.Println("starting the parse of the request payload...")
+	r.Logger.Debug("starting the parse of the request payload...")
 
 	var err error
 
@@ -292,7 +489,7 @@ func (r *APIRequest) parsePayload() {
 
 	for k, v := range r.Query {
 		queryKeys = append(queryKeys, k)
-		queryParameters[k] = v[0]
+		queryParameters[k] = v
 	}
 
 	// parse the body parameters
@@ -300,13 +497,19 @@ func (r *APIRequest) parsePayload() {
 	bodyParameters := make(map[string]interface{})
 
 	if len(r.Input) > 0 {
-		r.Logger.Println("this request got an body input")
+		r.Logger.Debug("this request got an body input", "size", len(r.Input))
 
 		// parse the input data into an interface
 		if r.ContentType == "json" {
 			err = json.Unmarshal(r.Input, &bodyParameters)
 			if err != nil {
-				r.updateResult("GEN-0011", err)
+				r.Logger.Info("failed to parse the body payload as JSON", "err", err)
+
+				r.updateResult("GEN-0011", utilfunc.Empty)
+				if r.debug {
+					r.updateResult("GEN-0011", err.Error())
+				}
+
 				return
 			}
 		} else {
@@ -340,16 +543,38 @@ func (r *APIRequest) parsePayload() {
 
 		if v.QueryParameter {
 			if !utilfunc.StringInSlice(v.Name, queryKeys) {
-				r.Logger.Printf("parameter missing at the URL query [param: %v]", v.Name)
+				if v.Optional {
+					continue
+				}
+
+				r.Logger.Debug("parameter missing at the URL query", "param", v.Name)
 
 				missing = append(missing, v)
 				continue
 			}
 
+			// the URL query values are strings, convert them into the numbers and booleans
+			if s, ok := queryParameters[v.Name].(string); ok {
+				switch v.Kind {
+				case "number":
+					if n, err := strconv.ParseFloat(s, 64); err == nil {
+						queryParameters[v.Name] = n
+					}
+				case "bool":
+					if b, err := strconv.ParseBool(s); err == nil {
+						queryParameters[v.Name] = b
+					}
+				}
+			}
+
 			methodParams = &queryParameters
 		} else {
 			if !utilfunc.StringInSlice(v.Name, bodyKeys) {
-				r.Logger.Printf("parameter missing at the body payload [param: %v]", v.Name)
+				if v.Optional {
+					continue
+				}
+
+				r.Logger.Debug("parameter missing at the body payload", "param", v.Name)
 
 				missing = append(missing, v)
 				continue
@@ -386,16 +611,17 @@ func (r *APIRequest) parsePayload() {
 				continue
 			}
 		default:
-			if (*methodParams)[v.Name] != nil && v.Required {
+			// the null values are only accepted by the parameters that are not required
+			if (*methodParams)[v.Name] != nil || v.Required {
 				invalid = append(invalid, v)
 				continue
 			}
 		}
 
 		// perform param data check for the "enum" type
-		if v.Kind == "enum" {
+		if v.Kind == "enum" && (*methodParams)[v.Name] != nil {
 			if !utilfunc.StringInSlice((*methodParams)[v.Name].(string), v.Options) {
-				r.Logger.Printf("parameter got an value that does not match the ENUM available ones [param: %v] [recieved: %v]", v.Name, (*methodParams)[v.Name].(string))
+				r.Logger.Debug("parameter got an value that does not match the ENUM available ones", "param", v.Name, "recieved", (*methodParams)[v.Name].(string))
 
 				invalid = append(invalid, v)
 				continue
@@ -407,7 +633,7 @@ func (r *APIRequest) parsePayload() {
 
 			// check the length of the recived data
 			if v.MaxLength != 0 && (utf8.RuneCountInString((*methodParams)[v.Name].(string)) > v.MaxLength) {
-				r.Logger.Printf("parameter surpass the max length for string [param: %v] [maxLength: %v]", v.Name, v.MaxLength)
+				r.Logger.Debug("parameter surpass the max length for string", "param", v.Name, "max_length", v.MaxLength)
 
 				invalid = append(invalid, v)
 				continue
@@ -418,13 +644,13 @@ func (r *APIRequest) parsePayload() {
 		// append this value into the parameters section
 		parameters[v.Name] = (*methodParams)[v.Name]
 
-		r.Logger.Printf("sucessfully extracted and parsed parameter [parameter: %v]", v.Name)
+		r.Logger.Debug("sucessfully extracted and parsed parameter", "param", v.Name)
 
 	}
 
 	// return the parameters that failed the verification
 	if len(invalid) > 0 || len(missing) > 0 {
-		r.Logger.Printf("this request has invalid or missing parameters [invalid: %v] [missing: %v]", len(invalid), len(missing))
+		r.Logger.Info("this request has invalid or missing parameters", "invalid", len(invalid), "missing", len(missing))
 
 		r.updateResult("GEN-0013", struct {
 			Missing *[]APIResourceParameter `json:"missing"`
@@ -440,7 +666,7 @@ func (r *APIRequest) parsePayload() {
 	// assign the parsed body on the request
 	r.Parameters = &parameters
 
-	r.Logger.Printf("sucessfully parsed parameters from the URL query and body payload [available: %v]", len(*r.Parameters))
+	r.Logger.Debug("sucessfully parsed parameters from the URL query and body payload", "available", len(*r.Parameters))
 
 }
 
@@ -450,10 +676,14 @@ func (r *APIRequest) validateResourceParameters(validators *map[string]APIParame
 		return
 	}
 
-	r.Logger.Println("starting the resource parameters validations...")
+	r.Logger.Debug("starting the resource parameters validations...")
 
 	// perform the resource params validations
 	for _, v := range r.Resource.Parameters {
+		if _, ok := (*r.Parameters)[v.Name]; !ok && v.Optional {
+			continue
+		}
+
 		for _, validator := range v.Validators {
 
 			// call the parameter validator
@@ -461,7 +691,7 @@ func (r *APIRequest) validateResourceParameters(validators *map[string]APIParame
 
 			// handle validator errors
 			if res.Code != "OK" {
-				r.Logger.Printf("parameter validation failed [param: %v] [validator: %v] [returnedCode: %v] [err: %v]", v.Name, validator, res.Code, res.Data)
+				r.Logger.Info("parameter validation failed", "param", v.Name, "validator", validator, "returned_code", res.Code, "err", res.Data)
 
 				r.updateResult(res.Code, struct {
 					Parameter     APIResourceParameter `json:"parameter"`
@@ -476,12 +706,12 @@ func (r *APIRequest) validateResourceParameters(validators *map[string]APIParame
 				return
 			}
 
-			r.Logger.Printf("parameter validation passed [param: %v] [validator: %v]", v.Name, validator)
+			r.Logger.Debug("parameter validation passed", "param", v.Name, "validator", validator)
 
 		}
 	}
 
-	r.Logger.Println("successfully validated parameters for this resource")
+	r.Logger.Debug("successfully validated parameters for this resource")
 
 }
 
@@ -491,7 +721,7 @@ func (r *APIRequest) callBackendPreExecution(be *Backend) {
 		return
 	}
 
-	r.Logger.Println("calling the before-method operation function at backend service...")
+	r.Logger.Debug("calling the before-method operation function at backend service...")
 
 	r.Result = (*be).APIBeforeMethodOperations(r)
 
@@ -505,34 +735,113 @@ func (r *APIRequest) callMethod(methods *map[string]APIResourceMethod) {
 
 	// check if the resource method function exists
 	if _, ok := (*methods)[r.Resource.ResourceMethod]; !ok {
-		r.Logger.Println("resource method function does not exists at the handlers map")
+		r.Logger.Error("resource method function does not exists at the handlers map", "function", r.Resource.ResourceMethod)
 
 		r.updateResult("GEN-0001", r.Resource.ResourceMethod)
 		return
 	}
 
-	r.Logger.Println("-- executing resource method --")
+	r.Logger.Debug("-- executing resource method --")
 
-	// handle panic at function call
-	defer func() {
-		if rcv := recover(); rcv != nil {
-			r.Logger.Printf("resource method function got in panic [err: %v]", rcv)
+	// bound the method execution with the resource timeout
+	parent := r.Context
 
-			r.Result = Result{"SE", rcv}
-		}
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	if r.Resource.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, time.Duration(r.Resource.Timeout)*time.Millisecond)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+
+	defer cancel()
+
+	// call the method with its own copy of the request, maps and parameters, merged
+	// back only if the method ends in time, as a late method can not change the response
+	call := *r
+	call.Context = ctx
+	call.Headers = maps.Clone(r.Headers)
+	call.Query = maps.Clone(r.Query)
+	call.cookies = append([]string(nil), r.cookies...)
+
+	if r.Parameters != nil {
+		parameters := copyParameter(*r.Parameters).(map[string]interface{})
+		call.Parameters = &parameters
+	}
+
+	done := make(chan Result, 1)
+	returned := make(chan struct{})
+
+	go func() {
+		defer close(returned)
+
+		// handle panic at function call
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				done <- call.recoverResult(rcv)
+			}
+		}()
+
+		done <- (*methods)[call.Resource.ResourceMethod](&call)
 	}()
 
-	r.Result = (*methods)[r.Resource.ResourceMethod](r)
+	// wait for the method result or the context end
+	select {
+	case res := <-done:
+		call.Context = parent
+		call.Result = res
+
+		*r = call
+	case <-ctx.Done():
+		r.abandoned = returned
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			r.Logger.Warn("resource method exceeded its execution time", "timeout_ms", r.Resource.Timeout)
+
+			r.updateResult("GEN-0015", utilfunc.Empty)
+			return
+		}
+
+		r.Logger.Warn("request context was cancelled while executing the resource method", "err", ctx.Err())
+
+		r.updateResult("GEN-0016", utilfunc.Empty)
+		return
+	}
 
 	// add the OK code if the return code is empty
 	if r.Result.Code == "" {
 		r.Result.Code = "OK"
 	}
 
-	r.Logger.Println("-- resource method execution ended --")
+	r.Logger.Debug("-- resource method execution ended --")
+
+	r.Logger.Debug("sucessfully executed the resource method function", "code", r.Result.Code)
 
-	r.Logger.Println("sucessfully executed the resource method function")
+}
+
+// return a deep copy of a parsed parameter, copying its maps and arrays.
+func copyParameter(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = copyParameter(e)
+		}
+
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = copyParameter(e)
+		}
+
+		return c
+	case []string:
+		return append([]string(nil), v...)
+	}
 
+	return v
 }
 
 // call the backend post execution function to perform backend logic.
@@ -541,7 +850,7 @@ func (r *APIRequest) callBackendPostExecution(be *Backend) {
 		return
 	}
 
-	r.Logger.Println("calling the after-method operation function at backend service...")
+	r.Logger.Debug("calling the after-method operation function at backend service...")
 
 	r.Result = (*be).APIAfterMethodOperations(r)
 
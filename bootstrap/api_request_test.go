@@ -0,0 +1,202 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestRequestBodyLimits(t *testing.T) {
+	app := newTestApplication()
+	app.MaxBodySize = 32
+
+	app.APIMethods["echo"] = func(r *APIRequest) Result { return Result{"OK", len(r.Input)} }
+
+	app.APIRoutes["small"] = map[string]APIResource{"POST": {ResourceMethod: "echo"}}
+	app.APIRoutes["large"] = map[string]APIResource{"POST": {ResourceMethod: "echo", MaxBodySize: 128}}
+
+	body := func(size int) string { return `{"v":"` + strings.Repeat("a", size-8) + `"}` }
+
+	tests := []struct {
+		name    string
+		target  string
+		body    string
+		headers map[string]string
+		status  int
+		code    string
+	}{
+		{"body within the application limit", "/small", body(32), nil, http.StatusOK, "OK"},
+		{"body above the application limit", "/small", body(33), nil, http.StatusRequestEntityTooLarge, "GEN-0018"},
+		{"body within the resource limit", "/large", body(100), nil, http.StatusOK, "OK"},
+		{"body above the resource limit", "/large", body(129), nil, http.StatusRequestEntityTooLarge, "GEN-0018"},
+		{"declared length above the limit", "/small", body(16), map[string]string{"Content-Length": "4096"}, http.StatusRequestEntityTooLarge, "GEN-0018"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serveTestRequest(t, app, "POST", tt.target, tt.body, tt.headers)
+
+			if res.Status != tt.status || res.Code != tt.code {
+				t.Fatalf("response = %v %v (%s), want %v %v", res.Status, res.Code, res.Data, tt.status, tt.code)
+			}
+
+			// the limit is returned to the client
+			if tt.code == "GEN-0018" {
+				var limit int64
+				if err := json.Unmarshal(res.Data, &limit); err != nil || limit != app.maxBodySize(&APIRequest{Resource: app.APIRoutes[tt.target[1:]]["POST"]}) {
+					t.Errorf("data = %s, want the max body size", res.Data)
+				}
+			}
+		})
+	}
+
+	// a body that fails while being read
+	t.Run("unreadable body", func(t *testing.T) {
+		res, err := app.APILambdaHandler(context.Background(), events.APIGatewayProxyRequest{
+			Path:            "/small",
+			Body:            "not base64!",
+			IsBase64Encoded: true,
+			RequestContext:  events.APIGatewayProxyRequestContext{HTTPMethod: "POST"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var envelope struct {
+			Meta APIMetadata `json:"meta"`
+		}
+
+		if err := json.Unmarshal([]byte(res.Body), &envelope); err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != http.StatusBadRequest || envelope.Meta.Code != "GEN-0019" {
+			t.Errorf("response = %v %v, want %v GEN-0019", res.StatusCode, envelope.Meta.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestRoutesFileParameters(t *testing.T) {
+	dir := t.TempDir()
+
+	// a routes file that declares its parameters without the "required" or "optional" flags
+	routesFile, codesFile := filepath.Join(dir, "routes.json"), filepath.Join(dir, "codes.json")
+
+	if err := os.WriteFile(codesFile, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(routesFile, []byte(`{"users": {"POST": {"function": "createUser", "parameters": [
+		{"name": "name", "kind": "string"},
+		{"name": "team", "kind": "string", "optional": true}
+	]}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, routes, err := readConfigFiles(codesFile, routesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApplication()
+	app.APIRoutes = routes
+	app.APIMethods["createUser"] = func(r *APIRequest) Result { return Result{"OK", (*r.Parameters)["name"]} }
+
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"all parameters", `{"name": "ana", "team": "a"}`, "OK"},
+		{"optional parameter absent", `{"name": "ana"}`, "OK"},
+		{"undeclared parameter absent", `{"team": "a"}`, "GEN-0013"},
+		{"optional parameter invalid", `{"name": "ana", "team": 1}`, "GEN-0013"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := serveTestRequest(t, app, "POST", "/users", tt.body, nil); res.Code != tt.code {
+				t.Errorf("code = %v (%s), want %v", res.Code, res.Data, tt.code)
+			}
+		})
+	}
+}
+
+func TestParameterPresenceFlags(t *testing.T) {
+	app := newTestApplication()
+	app.APIMethods["echo"] = func(r *APIRequest) Result { return Result{"OK", (*r.Parameters)["p"]} }
+
+	tests := []struct {
+		name     string
+		required bool
+		optional bool
+		kind     string
+		body     string
+		code     string
+	}{
+		{"mandatory parameter sent", false, false, "string", `{"p": "a"}`, "OK"},
+		{"mandatory parameter null", false, false, "string", `{"p": null}`, "OK"},
+		{"mandatory parameter absent", false, false, "string", `{}`, "GEN-0013"},
+		{"required parameter sent", true, false, "string", `{"p": "a"}`, "OK"},
+		{"required parameter null", true, false, "string", `{"p": null}`, "GEN-0013"},
+		{"required parameter absent", true, false, "string", `{}`, "GEN-0013"},
+		{"optional parameter sent", false, true, "string", `{"p": "a"}`, "OK"},
+		{"optional parameter null", false, true, "string", `{"p": null}`, "OK"},
+		{"optional parameter absent", false, true, "string", `{}`, "OK"},
+		{"optional parameter invalid", false, true, "string", `{"p": 1}`, "GEN-0013"},
+		{"optional and required parameter sent", true, true, "string", `{"p": "a"}`, "OK"},
+		{"optional and required parameter null", true, true, "string", `{"p": null}`, "GEN-0013"},
+		{"optional and required parameter absent", true, true, "string", `{}`, "OK"},
+		{"enum parameter null", false, false, "enum", `{"p": null}`, "OK"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.APIRoutes["echo"] = map[string]APIResource{"POST": {
+				ResourceMethod: "echo",
+				Parameters:     []APIResourceParameter{{Name: "p", Kind: tt.kind, Required: tt.required, Optional: tt.optional, Options: []string{"a"}}},
+			}}
+
+			if res := serveTestRequest(t, app, "POST", "/echo", tt.body, nil); res.Code != tt.code {
+				t.Errorf("code = %v (%s), want %v", res.Code, res.Data, tt.code)
+			}
+		})
+	}
+}
+
+func TestRequestConfigurationSnapshot(t *testing.T) {
+	app := newTestApplication()
+	app.Codes["APP-0001"] = Code{HTTPCode: http.StatusConflict, Message: map[string]string{"en-us": "Conflict"}}
+
+	// the method reloads the configuration while the request is handled
+	app.APIMethods["reload"] = func(r *APIRequest) Result {
+		codes := map[string]Code{"APP-0001": {HTTPCode: http.StatusTeapot}}
+		for k, v := range DefaultCodes {
+			codes[k] = v
+		}
+
+		unlock := app.lockConfig()
+		app.Codes, app.APIRoutes = codes, map[string]map[string]APIResource{}
+		unlock()
+
+		return Result{"APP-0001", nil}
+	}
+
+	app.APIRoutes["reload"] = map[string]APIResource{"GET": {ResourceMethod: "reload"}}
+
+	// the request is answered with the configuration it started with
+	if res := serveTestRequest(t, app, "GET", "/reload", "", nil); res.Status != http.StatusConflict || res.Code != "APP-0001" {
+		t.Errorf("response = %v %v, want %v APP-0001", res.Status, res.Code, http.StatusConflict)
+	}
+
+	// and the next requests with the reloaded one
+	if res := serveTestRequest(t, app, "GET", "/reload", "", nil); res.Code != "GEN-0004" {
+		t.Errorf("code after the reload = %v, want GEN-0004", res.Code)
+	}
+}
@@ -8,6 +8,7 @@ type APIResponse struct {
 	HTTPCode int               // HTTP response code of the result
 	Content  []byte            // response content
 	Headers  map[string]string // response headers
+	Cookies  []string          // "Set-Cookie" header values
 }
 
 // APIMetadata
@@ -17,23 +18,42 @@ type APIMetadata struct {
 	Time    time.Time         `json:"time"`    // datetime of the request answer
 	Code    string            `json:"code"`    // API response code
 	Message map[string]string `json:"message"` // response code messages
+
+	// pagination of the list resources
+	NextCursor string `json:"next_cursor,omitempty"` // opaque cursor of the next page
+	HasMore    *bool  `json:"has_more,omitempty"`    // if there are more pages after this one
 }
 
 // APIResource
 // define an API method within a route
 type APIResource struct {
-	ResourceMethod string                 `json:"function"`
-	Authentication bool                   `json:"authentication"` // if authentication token should be required
-	Network        APIResourceNetwork     `json:"network"`        // network based policies
-	Parameters     []APIResourceParameter `json:"parameters"`     // acceptable parameters for this action
+	ResourceMethod string                  `json:"function"`
+	Authentication bool                    `json:"authentication"` // if authentication token should be required
+	Network        APIResourceNetwork      `json:"network"`        // network based policies
+	Parameters     []APIResourceParameter  `json:"parameters"`     // acceptable parameters for this action
+	Middlewares    []string                `json:"middlewares"`    // names of the application route middlewares to wrap the method with
+	Timeout        int                     `json:"timeout"`        // max execution time of the method in milliseconds (0 for no limit), the late method keeps running on its own copy of the request
+	CORS           *CORSPolicy             `json:"cors"`           // cross-origin policy overriding the application one
+	Cache          *APIResourceCache       `json:"cache"`          // HTTP caching policy of the successful responses
+	MaxBodySize    int64                   `json:"max_body_size"`  // max request body size in bytes, overriding the application one
+	Idempotency    *APIResourceIdempotency `json:"idempotency"`    // replay of the responses of requests with an "Idempotency-Key"
+	Pagination     *APIResourcePagination  `json:"pagination"`     // "limit" and "cursor" query parameters of a list resource
+	Fields         *APIResourceFields      `json:"fields"`         // sparse fieldsets selected by the "fields" query parameter
+	Stream         bool                    `json:"stream"`         // if the function is a stream method, answering with Server-Sent Events
+	WebSocket      bool                    `json:"websocket"`      // if the function is a WebSocket handler, accepting connection upgrades (declared under GET)
 }
 
 // APIResourceParameter
-// define an parameter specification for the resource
+// define an parameter specification for the resource. the two flags are
+// independent: Optional is about the presence of the parameter at the request
+// (it must be sent unless optional) and Required about its value (a null one is
+// refused when required). an optional and required parameter may be absent,
+// but can not be null when sent.
 type APIResourceParameter struct {
 	Name           string   `json:"name"`            // parameter name
 	Kind           string   `json:"kind"`            // parameter type (string/number/enum)
-	Required       bool     `json:"required"`        // is required
+	Required       bool     `json:"required"`        // must not be null when sent
+	Optional       bool     `json:"optional"`        // may be absent from the request (present ones are still validated)
 	MaxLength      int      `json:"max_length"`      // max length of the string (0 for no limit)
 	QueryParameter bool     `json:"query_parameter"` // if this parameter should be extracted from the GET query
 	Options        []string `json:"options"`         // if type ENUM, this is a list of the available options
@@ -1,8 +1,9 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -12,6 +13,7 @@ import (
 	"github.com/bytedance/sonic"
 	"github.com/google/uuid"
 	"github.com/guregu/dynamo"
+	"go.opentelemetry.io/otel"
 )
 
 // instantiate an AWS session
@@ -46,7 +48,16 @@ func UpdateAWSConnections() {
 }
 
 // add an event into the SQS queue
-func AppendToQueue(l *log.Logger, key string, priority int, category string, data map[string]interface{}) {
+//
+// Deprecated: use AppendToQueueWithContext to forward the trace and correlation ID.
+func AppendToQueue(l *slog.Logger, key string, priority int, category string, data map[string]interface{}) {
+	AppendToQueueWithContext(context.Background(), l, key, priority, category, data)
+}
+
+// add an event into the SQS queue, forwarding the trace and correlation ID of the
+// passed context (as the request context, r.Context) as message attributes. the
+// event is sent even if the context is cancelled meanwhile (client gone or timeout)
+func AppendToQueueWithContext(ctx context.Context, l *slog.Logger, key string, priority int, category string, data map[string]interface{}) {
 
 	// marshal the JSON to send
 	body, _ := sonic.MarshalString(data)
@@ -60,9 +71,9 @@ func AppendToQueue(l *log.Logger, key string, priority int, category string, dat
 	)
 
 	// send to the queue
-	out, err := QUEUE.SendMessage(&sqs.SendMessageInput{
+	out, err := QUEUE.SendMessageWithContext(context.WithoutCancel(ctx), &sqs.SendMessageInput{
 		QueueUrl:               aws.String(os.Getenv("APP_QUEUE")),
-		MessageAttributes:      map[string]*sqs.MessageAttributeValue{"METHOD": {DataType: aws.String("String"), StringValue: &category}},
+		MessageAttributes:      queueMessageAttributes(ctx, category),
 		MessageBody:            &body,
 		MessageDeduplicationId: &dedup,
 		MessageGroupId:         aws.String(fmt.Sprintf("%v-%v", key, priority)),
@@ -73,8 +84,22 @@ func AppendToQueue(l *log.Logger, key string, priority int, category string, dat
 		panic(err)
 	}
 
-	l.Printf("sucessfully added an event into the SQS queue [id: %v]", &out.MessageId)
+	l.Info("sucessfully added an event into the SQS queue", "message_id", aws.StringValue(out.MessageId), "method", category)
+
+}
+
+// return the attributes of a queue message, holding its method,
+// the trace context and the correlation ID of the passed context
+func queueMessageAttributes(ctx context.Context, category string) map[string]*sqs.MessageAttributeValue {
+	attributes := map[string]*sqs.MessageAttributeValue{"METHOD": {DataType: aws.String("String"), StringValue: aws.String(category)}}
+
+	otel.GetTextMapPropagator().Inject(ctx, sqsAttributesCarrier(attributes))
+
+	if id := RequestIDFromContext(ctx); id != "" {
+		attributes[requestIDAttribute] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(id)}
+	}
 
+	return attributes
 }
 
 // return an AWS session created upon the passed parameters
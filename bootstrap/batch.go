@@ -0,0 +1,181 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ncastellani/partida/utilfunc"
+)
+
+// name of the resource method of the built-in batch route
+const batchMethod = "partida.batch"
+
+// headers of the batch request that are not shared with its sub-requests
+var batchExcludedHeaders = []string{
+	"content-length", "content-type", "content-encoding", "accept", "accept-encoding",
+	"idempotency-key", "if-none-match", "if-modified-since", "traceparent", "tracestate",
+}
+
+// headers of the sub-requests that would answer them with a compressed, non JSON
+// or empty body, which can not be embedded into the batch response
+var batchSubRequestExcludedHeaders = []string{
+	"content-length", "content-encoding", "accept", "accept-encoding", "if-none-match", "if-modified-since",
+}
+
+// BatchSettings
+// define the limits of the built-in batch route (see EnableBatchRoute).
+type BatchSettings struct {
+	MaxRequests int // max amount of sub-requests per batch, default = 20
+}
+
+// BatchRequest
+// define a sub-request of a batch. the headers of the batch request (as the
+// "Authorization" one) are shared with it, being overridden by its own ones.
+type BatchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   map[string]string `json:"query"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// BatchResult
+// define the answer of a sub-request, with its enveloped response.
+type BatchResult struct {
+	Status int             `json:"status" xml:"status"`
+	Body   json.RawMessage `json:"body" xml:"body"`
+}
+
+// EnableBatchRoute
+// register the built-in batch route as a POST resource that calls each one of
+// its sub-requests through the API pipeline, sequentially or in parallel.
+// the other methods of the route are kept. as each sub-request runs the whole
+// pipeline, the authentication and authorization are performed once per
+// sub-request, and the sub-requests share the IP address of the batch request.
+func (app *Application) EnableBatchRoute(route string) {
+	app.Route(route).POST(app.callBatch).
+		Name(batchMethod).
+		Network("allow").
+		Param(APIResourceParameter{Name: "requests", Kind: "array", Required: true})
+}
+
+// callBatch
+// call the sub-requests of a batch and return their enveloped responses.
+func (app *Application) callBatch(r *APIRequest) Result {
+	if r.batched {
+		r.Logger.Info("batch request was called within another batch")
+
+		return Result{"GEN-0025", utilfunc.Empty}
+	}
+
+	// parse the sub-requests from the body (validated by the resource parameters)
+	var batch struct {
+		Requests []BatchRequest `json:"requests"`
+		Parallel bool           `json:"parallel"`
+	}
+
+	if err := json.Unmarshal(r.Input, &batch); err != nil {
+		r.Logger.Info("failed to parse the batch sub-requests", "err", err)
+
+		return Result{"GEN-0011", utilfunc.Empty}
+	}
+
+	max := app.BatchSettings.MaxRequests
+	if max == 0 {
+		max = 20
+	}
+
+	if len(batch.Requests) == 0 || len(batch.Requests) > max {
+		r.Logger.Info("batch request holds an invalid amount of sub-requests", "amount", len(batch.Requests), "max", max)
+
+		return Result{"GEN-0024", max}
+	}
+
+	r.Logger.Debug("calling the batch sub-requests", "amount", len(batch.Requests), "parallel", batch.Parallel)
+
+	// call the sub-requests through the API pipeline
+	results := make([]BatchResult, len(batch.Requests))
+
+	if batch.Parallel {
+		var wg sync.WaitGroup
+
+		for i, v := range batch.Requests {
+			wg.Add(1)
+
+			go func(i int, v BatchRequest) {
+				defer wg.Done()
+
+				results[i] = app.callBatchRequest(r, i, v)
+			}(i, v)
+		}
+
+		wg.Wait()
+	} else {
+		for i, v := range batch.Requests {
+			results[i] = app.callBatchRequest(r, i, v)
+		}
+	}
+
+	return Result{"OK", results}
+}
+
+// callBatchRequest
+// assemble a sub-request from the batch request and handle it.
+func (app *Application) callBatchRequest(batch *APIRequest, index int, v BatchRequest) BatchResult {
+
+	// share the batch request headers, except the ones about its own body and representation
+	headers := make(map[string]string)
+
+	for k, val := range batch.Headers {
+		if !utilfunc.StringInSlice(strings.ToLower(k), batchExcludedHeaders) {
+			headers[http.CanonicalHeaderKey(k)] = val
+		}
+	}
+
+	for k, val := range v.Headers {
+		if !utilfunc.StringInSlice(strings.ToLower(k), batchSubRequestExcludedHeaders) {
+			headers[http.CanonicalHeaderKey(k)] = val
+		}
+	}
+
+	if v.Query == nil {
+		v.Query = make(map[string]string)
+	}
+
+	// parse the path for getting the action
+	path := strings.TrimPrefix(v.Path, "/")
+	if path == "" {
+		path = "index"
+	}
+
+	r := APIRequest{
+		ID:      fmt.Sprintf("%v.%v", batch.ID, index),
+		Context: batch.Context,
+		IP:      batch.IP,
+		Query:   v.Query,
+		Headers: headers,
+		Method:  strings.ToUpper(v.Method),
+		Path:    path,
+		batched: true,
+	}
+
+	if len(v.Body) > 0 && !bytes.Equal(v.Body, []byte("null")) {
+		r.SetBody(bytes.NewReader(v.Body))
+	}
+
+	res := app.handleAPIRequest(&r)
+
+	r.Logger.Info("batch sub-request answered", "batch_id", batch.ID, "status", res.HTTPCode, "code", r.Result.Code)
+
+	// responses without content are answered with a null body
+	body := json.RawMessage("null")
+	if len(res.Content) > 0 {
+		body = res.Content
+	}
+
+	return BatchResult{Status: res.HTTPCode, Body: body}
+}
@@ -0,0 +1,123 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestBatchRoute(t *testing.T) {
+	app := newTestApplication()
+	app.BatchSettings.MaxRequests = 3
+	app.CompressionSettings = CompressionSettings{Enabled: true, MinSize: 1}
+	app.EnableBatchRoute("batch")
+
+	app.APIMethods["whoami"] = func(r *APIRequest) Result { return Result{"OK", r.Headers["Authorization"]} }
+	app.APIMethods["echo"] = func(r *APIRequest) Result { return Result{"OK", (*r.Parameters)["name"]} }
+
+	app.APIRoutes["whoami"] = map[string]APIResource{"GET": {ResourceMethod: "whoami"}}
+	app.APIRoutes["echo"] = map[string]APIResource{"POST": {
+		ResourceMethod: "echo",
+		Parameters:     []APIResourceParameter{{Name: "name", Kind: "string", Required: true}},
+	}}
+
+	// the status and code of each sub-request response
+	type answer struct {
+		Status int
+		Code   string
+		Data   string
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		code    string
+		answers []answer
+	}{
+		{
+			"sequential sub-requests sharing the batch headers",
+			`{"requests": [
+				{"method": "GET", "path": "/whoami"},
+				{"method": "GET", "path": "/whoami", "headers": {"Authorization": "Bearer other"}},
+				{"method": "GET", "path": "/nope"}
+			]}`,
+			"OK",
+			[]answer{{200, "OK", `"Bearer token"`}, {200, "OK", `"Bearer other"`}, {404, "GEN-0004", "null"}},
+		},
+		{
+			"parallel sub-requests keep their order",
+			`{"parallel": true, "requests": [
+				{"method": "POST", "path": "echo", "body": {"name": "a"}},
+				{"method": "POST", "path": "echo", "body": {"name": "b"}},
+				{"method": "POST", "path": "echo"}
+			]}`,
+			"OK",
+			[]answer{{200, "OK", `"a"`}, {200, "OK", `"b"`}, {406, "GEN-0013", ""}},
+		},
+		{
+			"sub-request representation headers ignored",
+			`{"requests": [{"method": "GET", "path": "whoami", "headers": {"Accept-Encoding": "gzip", "Accept": "application/xml", "If-None-Match": "*"}}]}`,
+			"OK",
+			[]answer{{200, "OK", `"Bearer token"`}},
+		},
+		{
+			"batch within a batch",
+			`{"requests": [{"method": "POST", "path": "batch", "body": {"requests": [{"method": "GET", "path": "whoami"}]}}]}`,
+			"OK",
+			[]answer{{400, "GEN-0025", "null"}},
+		},
+		{"no sub-requests", `{"requests": []}`, "GEN-0024", nil},
+		{"too many sub-requests", `{"requests": [{}, {}, {}, {}]}`, "GEN-0024", nil},
+		{"missing sub-requests", `{}`, "GEN-0013", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serveTestRequest(t, app, "POST", "/batch", tt.body, map[string]string{"Authorization": "Bearer token"})
+
+			if res.Code != tt.code {
+				t.Fatalf("code = %v (%s), want %v", res.Code, res.Data, tt.code)
+			}
+
+			if tt.answers == nil {
+				return
+			}
+
+			var results []BatchResult
+			if err := json.Unmarshal(res.Data, &results); err != nil {
+				t.Fatal(err)
+			}
+
+			var answers []answer
+
+			for _, v := range results {
+				var envelope struct {
+					Meta APIMetadata     `json:"meta"`
+					Data json.RawMessage `json:"data"`
+				}
+
+				if err := json.Unmarshal(v.Body, &envelope); err != nil {
+					t.Fatal(err)
+				}
+
+				// the data of the failed parameters is not compared
+				data := string(envelope.Data)
+				if envelope.Meta.Code == "GEN-0013" {
+					data = ""
+				}
+
+				answers = append(answers, answer{v.Status, envelope.Meta.Code, data})
+			}
+
+			if !reflect.DeepEqual(answers, tt.answers) {
+				t.Errorf("answers = %v, want %v", answers, tt.answers)
+			}
+		})
+	}
+
+	// the batch route only accepts POST requests
+	if res := serveTestRequest(t, app, "GET", "/batch", "", nil); res.Status != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %v, want %v", res.Status, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,214 @@
+package bootstrap
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// type of the values decoded from JSON strings
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// ResultError
+// define an error that answers the request with a result code, returned by
+// the typed methods in place of their output.
+type ResultError struct {
+	Result
+}
+
+// Error
+// return the result code of the error.
+func (e ResultError) Error() string {
+	return fmt.Sprintf("request answered with the code %v", e.Code)
+}
+
+// NewResultError
+// return an error that answers the request with the result code and data.
+func NewResultError(code string, data interface{}) error {
+	return ResultError{Result{code, data}}
+}
+
+// TypedMethod
+// define a resource method that receives the request parameters bound to its
+// input type and returns its output as the result data. errors that are not
+// a ResultError are answered as internal errors.
+type TypedMethod[In, Out any] func(r *APIRequest, in In) (Out, error)
+
+// BindError
+// define a failure to bind the request parameters into the input type.
+type BindError struct {
+	Field string `json:"field" xml:"field"` // parameter that could not be bound (empty if unknown)
+	Error string `json:"error" xml:"error"` // binding failure details
+}
+
+// Bind
+// decode the validated request parameters into a value of the type T, using
+// the "json" tags of its fields as the parameter names.
+func Bind[T any](r *APIRequest) (v T, err error) {
+	if r.Parameters == nil {
+		return
+	}
+
+	b, err := json.Marshal(*r.Parameters)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(b, &v)
+
+	return
+}
+
+// bindResult
+// return the GEN-0030 result for a binding failure.
+func bindResult(err error) Result {
+	data := BindError{Error: err.Error()}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		data.Field = typeErr.Field
+		data.Error = fmt.Sprintf("expected a value of type %v, got %v", typeErr.Type, typeErr.Value)
+	}
+
+	return Result{"GEN-0030", data}
+}
+
+// WrapTypedMethod
+// convert a typed method into a resource method that binds its input and
+// answers with its output.
+func WrapTypedMethod[In, Out any](method TypedMethod[In, Out]) APIResourceMethod {
+	return func(r *APIRequest) Result {
+		in, err := Bind[In](r)
+		if err != nil {
+			r.Logger.Info("failed to bind the request parameters into the method input", "err", err)
+
+			return bindResult(err)
+		}
+
+		out, err := method(r, in)
+		if err != nil {
+			var res ResultError
+			if errors.As(err, &res) {
+				return res.Result
+			}
+
+			return Result{"SE", err}
+		}
+
+		return Result{"OK", out}
+	}
+}
+
+// AddTypedRoute
+// register a resource with a typed method from the code (see Application.Route),
+// with the parameters derived from the input type (see ParametersOf). the
+// returned builder sets the other rules of the resource.
+func AddTypedRoute[In, Out any](app *Application, route, httpMethod string, method TypedMethod[In, Out]) *ResourceBuilder {
+	return app.Route(route).Handle(httpMethod, WrapTypedMethod(method)).Params(ParametersOf[In]()...)
+}
+
+// ParametersOf
+// derive the resource parameters from the fields of a struct type. the
+// parameter names are taken from the "json" tags and their rules from the
+// "partida" tags, as comma separated options:
+//   - "required": the parameter must be sent and not null (the others are optional)
+//   - "query": the parameter is extracted from the URL query
+//   - "kind=K": kind of the parameter, overriding the one of the field type
+//   - "max=N": max length of the string
+//   - "enum=a|b": available options of the string (as an "enum" parameter)
+//   - "validators=a|b": custom validators of the parameter
+func ParametersOf[T any]() []APIResourceParameter {
+	return parametersOfType(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// parametersOfType
+// derive the resource parameters from the fields of a struct type.
+func parametersOfType(t reflect.Type) (params []APIResourceParameter) {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		// include the fields of the embedded structs as encoding/json does
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+
+		if f.Anonymous && name == "" && indirectType(f.Type).Kind() == reflect.Struct {
+			params = append(params, parametersOfType(f.Type)...)
+			continue
+		}
+
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		p := APIResourceParameter{Name: name, Kind: parameterKind(f.Type), Optional: true}
+
+		for _, opt := range strings.Split(f.Tag.Get("partida"), ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+
+			switch key {
+			case "required":
+				p.Required, p.Optional = true, false
+			case "query":
+				p.QueryParameter = true
+			case "kind":
+				p.Kind = value
+			case "max":
+				p.MaxLength, _ = strconv.Atoi(value)
+			case "enum":
+				p.Kind, p.Options = "enum", strings.Split(value, "|")
+			case "validators":
+				p.Validators = strings.Split(value, "|")
+			}
+		}
+
+		params = append(params, p)
+	}
+
+	return
+}
+
+// indirectType
+// return the type pointed by the pointer types.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+// parameterKind
+// determine the parameter kind of a Go type.
+// the types that unmarshal from text (as time.Time) are strings.
+func parameterKind(t reflect.Type) string {
+	if reflect.PointerTo(indirectType(t)).Implements(textUnmarshalerType) {
+		return "string"
+	}
+
+	switch indirectType(t).Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "map"
+	}
+
+	return "string"
+}
@@ -0,0 +1,86 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type searchInput struct {
+	Name   string `json:"name" partida:"query,required,max=4"`
+	Limit  int    `json:"limit" partida:"query"`
+	Active bool   `json:"active" partida:"query"`
+}
+
+func TestParametersOf(t *testing.T) {
+	type embedded struct {
+		ID string `json:"id" partida:"required"`
+	}
+
+	type input struct {
+		embedded
+		Kind    string   `json:"kind" partida:"enum=a|b"`
+		Tags    []string `json:"tags"`
+		Ignored string   `json:"-"`
+		hidden  string
+	}
+
+	got := ParametersOf[input]()
+	want := []APIResourceParameter{
+		{Name: "id", Kind: "string", Required: true},
+		{Name: "kind", Kind: "enum", Optional: true, Options: []string{"a", "b"}},
+		{Name: "tags", Kind: "array", Optional: true},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParametersOf() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTypedRouteQueryParameters(t *testing.T) {
+	app := newTestApplication()
+
+	AddTypedRoute(app, "/search/", "GET", func(r *APIRequest, in searchInput) (searchInput, error) {
+		return in, nil
+	}).Name("search")
+
+	if _, ok := app.APIMethods["search"]; !ok || len(app.APIRoutes["search"]["GET"].Parameters) != 3 {
+		t.Fatalf("registered resource = %+v", app.APIRoutes["search"]["GET"])
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		code   string
+		want   searchInput
+	}{
+		{"all parameters", "/search?name=ana&limit=5&active=true", "OK", searchInput{Name: "ana", Limit: 5, Active: true}},
+		{"optional parameters absent", "/search?name=ana", "OK", searchInput{Name: "ana"}},
+		{"required parameter absent", "/search?limit=5", "GEN-0013", searchInput{}},
+		{"number of invalid format", "/search?name=ana&limit=five", "GEN-0013", searchInput{}},
+		{"string over the max length", "/search?name=anabela", "GEN-0013", searchInput{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serveTestRequest(t, app, "GET", tt.target, "", nil)
+
+			if res.Code != tt.code {
+				t.Fatalf("code = %v (%s), want %v", res.Code, res.Data, tt.code)
+			}
+
+			if tt.code != "OK" {
+				return
+			}
+
+			var got searchInput
+			if err := json.Unmarshal(res.Data, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("bound input = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package bootstrap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// default "Cache-Control" of the responses without a cache policy
+const defaultCacheControl = "max-age=0,private,must-revalidate,no-cache"
+
+// APIResourceCache
+// define the HTTP caching policy of the successful responses of a resource.
+type APIResourceCache struct {
+	Public               bool `json:"public"`                 // if shared caches (CDNs) can store the response
+	MaxAge               int  `json:"max_age"`                // seconds the response is fresh for the clients
+	SharedMaxAge         int  `json:"s_maxage"`               // seconds the response is fresh for shared caches (0 for max_age)
+	StaleWhileRevalidate int  `json:"stale_while_revalidate"` // seconds a stale response can be used while revalidating
+	MustRevalidate       bool `json:"must_revalidate"`        // if stale responses must be revalidated before use
+}
+
+// cacheControl
+// return the "Cache-Control" header value of the policy.
+func (c *APIResourceCache) cacheControl() string {
+	directives := []string{"private"}
+	if c.Public {
+		directives = []string{"public"}
+	}
+
+	directives = append(directives, fmt.Sprintf("max-age=%v", c.MaxAge))
+
+	if c.SharedMaxAge > 0 {
+		directives = append(directives, fmt.Sprintf("s-maxage=%v", c.SharedMaxAge))
+	}
+
+	if c.StaleWhileRevalidate > 0 {
+		directives = append(directives, fmt.Sprintf("stale-while-revalidate=%v", c.StaleWhileRevalidate))
+	}
+
+	if c.MustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+
+	return strings.Join(directives, ",")
+}
+
+// setCacheHeaders
+// set the caching headers of a successful GET/HEAD response, with the resource
+// policy and a strong ETag of its data, and check its conditional headers.
+// return true if the client representation is still valid (not modified).
+func (r *APIRequest) setCacheHeaders(headers map[string]string) (notModified bool) {
+	if (r.Method != "GET" && r.Method != "HEAD") || r.Result.Code != "OK" {
+		return false
+	}
+
+	if r.Resource.Cache != nil {
+		headers["Cache-Control"] = r.Resource.Cache.cacheControl()
+	}
+
+	// compute the ETag from the serialized data and its representation
+	data, err := json.Marshal(r.Result.Data)
+	if err != nil {
+		r.Logger.Warn("failed to serialize the response data for its ETag", "err", err)
+		return false
+	}
+
+	sum := sha256.Sum256(append([]byte(r.ContentType+":"), data...))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	headers["ETag"] = etag
+
+	if !r.LastModified.IsZero() {
+		headers["Last-Modified"] = r.LastModified.UTC().Format(http.TimeFormat)
+	}
+
+	// evaluate the conditional headers. "If-Modified-Since" is
+	// ignored when "If-None-Match" is present (RFC 9110 13.1.3)
+	conditions := headersCarrier(r.Headers)
+
+	if v := conditions.Get("If-None-Match"); v != "" {
+		return etagMatches(v, etag)
+	}
+
+	if v := conditions.Get("If-Modified-Since"); v != "" && !r.LastModified.IsZero() {
+		since, err := http.ParseTime(v)
+		if err != nil {
+			return false
+		}
+
+		return !r.LastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// etagMatches
+// check if the "If-None-Match" list holds the ETag, using the weak comparison
+// and ignoring the content encoding suffix of compressed representations.
+func etagMatches(list, etag string) bool {
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if i := strings.Index(v, "-"); i != -1 {
+			v = v[:i] + `"`
+		}
+
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,75 @@
+package bootstrap
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	app := newTestApplication()
+
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	app.APIMethods["doc"] = func(r *APIRequest) Result {
+		r.LastModified = modified
+		return Result{"OK", map[string]string{"title": "partida"}}
+	}
+
+	app.APIRoutes["doc"] = map[string]APIResource{
+		"GET":  {ResourceMethod: "doc", Cache: &APIResourceCache{Public: true, MaxAge: 60, MustRevalidate: true}},
+		"POST": {ResourceMethod: "doc"},
+	}
+
+	// fetch the representation the client holds
+	res := serveTestRequest(t, app, "GET", "/doc", "", nil)
+
+	etag := res.Headers["Etag"]
+	if etag == "" {
+		t.Fatal("no ETag set on the response")
+	}
+
+	if res.Headers["Cache-Control"] != "public,max-age=60,must-revalidate" {
+		t.Errorf("Cache-Control = %q", res.Headers["Cache-Control"])
+	}
+
+	if res.Headers["Last-Modified"] != modified.Format(http.TimeFormat) {
+		t.Errorf("Last-Modified = %q", res.Headers["Last-Modified"])
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		status  int
+	}{
+		{"matching ETag", "GET", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"weak ETag in a list", "GET", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"ETag of a compressed representation", "GET", map[string]string{"If-None-Match": strings.TrimSuffix(etag, `"`) + `-gzip"`}, http.StatusNotModified},
+		{"any ETag", "GET", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"changed ETag", "GET", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"not modified since", "GET", map[string]string{"If-Modified-Since": modified.Add(time.Hour).Format(http.TimeFormat)}, http.StatusNotModified},
+		{"modified since", "GET", map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		{"ETag takes precedence over the date", "GET", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": modified.Format(http.TimeFormat)}, http.StatusOK},
+		{"unsafe methods are not conditional", "POST", map[string]string{"If-None-Match": "*"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serveTestRequest(t, app, tt.method, "/doc", "", tt.headers)
+
+			if res.Status != tt.status {
+				t.Fatalf("status = %v, want %v", res.Status, tt.status)
+			}
+
+			if tt.status == http.StatusNotModified && (res.Data != nil || res.Headers["Etag"] != etag) {
+				t.Errorf("not modified response = %s (ETag %q), want no body and ETag %q", res.Data, res.Headers["Etag"], etag)
+			}
+
+			if tt.method == "POST" && res.Headers["Etag"] != "" {
+				t.Errorf("ETag = %q on an unsafe method", res.Headers["Etag"])
+			}
+		})
+	}
+}
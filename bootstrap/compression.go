@@ -0,0 +1,150 @@
+package bootstrap
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ncastellani/partida/utilfunc"
+)
+
+// CompressionSettings
+// define the compression of the response bodies negotiated with the "Accept-Encoding" header.
+type CompressionSettings struct {
+	Enabled   bool     // compress the response bodies
+	MinSize   int      // min body size in bytes to be compressed, default = 1024
+	Encodings []string // supported encodings in order of preference, default = br, zstd, gzip
+}
+
+// default encodings in order of preference
+var defaultEncodings = []string{"br", "zstd", "gzip"}
+
+// zstd encoder shared by the requests (EncodeAll is safe for concurrent use)
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+// compressResponse
+// compress the response content with the encoding negotiated with the client,
+// setting the "Content-Encoding" and "Vary" headers of the response.
+func (app *Application) compressResponse(r *APIRequest, res *APIResponse) {
+	if !app.CompressionSettings.Enabled || res.Headers == nil || res.Headers["Content-Encoding"] != "" {
+		return
+	}
+
+	minSize := app.CompressionSettings.MinSize
+	if minSize == 0 {
+		minSize = 1024
+	}
+
+	// responses that may be compressed depend on the accepted encodings
+	addVaryHeader(res.Headers, "Accept-Encoding")
+
+	if len(res.Content) < minSize {
+		return
+	}
+
+	encodings := app.CompressionSettings.Encodings
+	if len(encodings) == 0 {
+		encodings = defaultEncodings
+	}
+
+	encoding := negotiateEncoding(headersCarrier(r.Headers).Get("Accept-Encoding"), encodings)
+	if encoding == "" {
+		return
+	}
+
+	// compress the content with the chosen encoding
+	compressed, err := compressContent(encoding, res.Content)
+	if err != nil {
+		r.Logger.Warn("failed to compress the response content", "encoding", encoding, "err", err)
+		return
+	}
+
+	r.Logger.Debug("compressed the response content", "encoding", encoding, "size", len(res.Content), "compressed", len(compressed))
+
+	res.Content = compressed
+	res.Headers["Content-Encoding"] = encoding
+
+	// the compressed body is a different representation of the data
+	if etag, ok := res.Headers["ETag"]; ok {
+		res.Headers["ETag"] = strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+	}
+}
+
+// negotiateEncoding
+// choose the supported encoding with the highest quality at the "Accept-Encoding"
+// header, breaking ties by the order of preference of the supported ones.
+func negotiateEncoding(header string, supported []string) (chosen string) {
+	if header == "" {
+		return
+	}
+
+	// parse the accepted encodings and their quality values
+	qualities := make(map[string]float64)
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		qualities[name] = q
+	}
+
+	// pick the best supported encoding
+	best := 0.0
+
+	for _, encoding := range supported {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+
+		if ok && q > best {
+			chosen, best = encoding, q
+		}
+	}
+
+	return
+}
+
+// compressContent
+// compress the content with the passed encoding.
+func compressContent(encoding string, content []byte) ([]byte, error) {
+	switch encoding {
+	case "gzip":
+		compressed, err := utilfunc.GzipFile(&content)
+		if err != nil {
+			return nil, err
+		}
+
+		return *compressed, nil
+	case "br":
+		var b bytes.Buffer
+
+		w := brotli.NewWriterLevel(&b, brotli.DefaultCompression)
+		if _, err := w.Write(content); err != nil {
+			return nil, err
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		return b.Bytes(), nil
+	case "zstd":
+		return zstdEncoder.EncodeAll(content, nil), nil
+	}
+
+	return nil, fmt.Errorf("unsupported content encoding [encoding: %v]", encoding)
+}
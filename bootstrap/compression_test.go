@@ -0,0 +1,109 @@
+package bootstrap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		encoding string
+	}{
+		{"no header", "", ""},
+		{"single encoding", "gzip", "gzip"},
+		{"ties broken by the server preference", "gzip, zstd, br", "br"},
+		{"highest quality", "br;q=0.5, gzip;q=0.9", "gzip"},
+		{"refused encoding", "br;q=0, gzip", "gzip"},
+		{"wildcard", "*", "br"},
+		{"wildcard with a refused encoding", "br;q=0, *;q=0.5", "zstd"},
+		{"case insensitive", "GZIP", "gzip"},
+		{"unsupported encoding", "deflate", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if encoding := negotiateEncoding(tt.header, defaultEncodings); encoding != tt.encoding {
+				t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, encoding, tt.encoding)
+			}
+		})
+	}
+}
+
+func TestCompressResponse(t *testing.T) {
+	app := newTestApplication()
+	app.CompressionSettings = CompressionSettings{Enabled: true, MinSize: 16}
+
+	content := []byte(strings.Repeat(`{"name":"partida"}`, 10))
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+
+	tests := []struct {
+		name     string
+		accept   string
+		content  []byte
+		encoding string
+	}{
+		{"gzip", "gzip", content, "gzip"},
+		{"brotli", "br", content, "br"},
+		{"zstd", "zstd", content, "zstd"},
+		{"identity", "", content, ""},
+		{"below the min size", "gzip", content[:8], ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &APIRequest{Headers: map[string]string{"Accept-Encoding": tt.accept}, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+			res := APIResponse{Content: tt.content, Headers: map[string]string{"ETag": `"abc"`}}
+
+			app.compressResponse(r, &res)
+
+			if res.Headers["Content-Encoding"] != tt.encoding {
+				t.Fatalf("Content-Encoding = %q, want %q", res.Headers["Content-Encoding"], tt.encoding)
+			}
+
+			if res.Headers["Vary"] != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", res.Headers["Vary"])
+			}
+
+			if tt.encoding == "" {
+				if !bytes.Equal(res.Content, tt.content) || res.Headers["ETag"] != `"abc"` {
+					t.Errorf("uncompressed response changed: %q (ETag %v)", res.Content, res.Headers["ETag"])
+				}
+
+				return
+			}
+
+			if res.Headers["ETag"] != `"abc-`+tt.encoding+`"` {
+				t.Errorf("ETag = %v, want the encoding suffix", res.Headers["ETag"])
+			}
+
+			// decode the compressed content back
+			dr, err := decoders[tt.encoding](bytes.NewReader(res.Content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := io.ReadAll(dr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(decoded, tt.content) {
+				t.Errorf("decoded content = %q, want %q", decoded, tt.content)
+			}
+		})
+	}
+}
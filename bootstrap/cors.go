@@ -0,0 +1,122 @@
+package bootstrap
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CORSPolicy
+// define the cross-origin requests policy of the application or of a resource.
+type CORSPolicy struct {
+	AllowedOrigins   []string `json:"allowed_origins"`   // allowed origins, "*" for any or patterns like "https://*.example.com"
+	AllowedHeaders   []string `json:"allowed_headers"`   // request headers allowed at preflights (empty to allow the requested ones)
+	ExposedHeaders   []string `json:"exposed_headers"`   // response headers exposed to the browser scripts
+	AllowCredentials bool     `json:"allow_credentials"` // if cookies and authorization headers are allowed (not for the "*" origin)
+	MaxAge           int      `json:"max_age"`           // seconds that the preflight response can be cached (0 for none)
+}
+
+// allowsOrigin
+// check if the origin matches one of the allowed origins or patterns.
+func (p *CORSPolicy) allowsOrigin(origin string) (allowed, wildcard bool) {
+	for _, v := range p.AllowedOrigins {
+		if v == "*" {
+			return true, true
+		}
+
+		if strings.EqualFold(v, origin) {
+			return true, false
+		}
+
+		if ok, _ := path.Match(strings.ToLower(v), strings.ToLower(origin)); ok {
+			return true, false
+		}
+	}
+
+	return false, false
+}
+
+// setCORSHeaders
+// set the CORS headers of the response following the resource policy
+// or the application one. disallowed origins get no CORS headers.
+func (app *Application) setCORSHeaders(r *APIRequest, headers map[string]string) {
+	policy := &app.CORS
+	if r.Resource.CORS != nil {
+		policy = r.Resource.CORS
+	}
+
+	origin := headersCarrier(r.Headers).Get("Origin")
+
+	// the response depends on the origin unless any origin is answered with "*"
+	allowed, wildcard := policy.allowsOrigin(origin)
+
+	if !wildcard {
+		addVaryHeader(headers, "Origin")
+	}
+
+	if origin == "" || !allowed {
+		return
+	}
+
+	// the "*" origin is never reflected, so the credentials are only
+	// allowed for the origins listed explicitly or by pattern
+	if wildcard {
+		headers["Access-Control-Allow-Origin"] = "*"
+	} else {
+		headers["Access-Control-Allow-Origin"] = origin
+
+		if policy.AllowCredentials {
+			headers["Access-Control-Allow-Credentials"] = "true"
+		}
+	}
+
+	if len(policy.ExposedHeaders) > 0 {
+		headers["Access-Control-Expose-Headers"] = strings.Join(policy.ExposedHeaders, ", ")
+	}
+
+	// set the preflight headers
+	if r.Result.Code != "GEN-0005" {
+		return
+	}
+
+	resources := r.configuration(app).routes[r.Path]
+
+	methods := make([]string, 0, len(resources))
+	for k := range resources {
+		methods = append(methods, k)
+	}
+
+	sort.Strings(methods)
+
+	headers["Access-Control-Allow-Methods"] = strings.Join(methods, ", ")
+
+	if len(policy.AllowedHeaders) > 0 {
+		headers["Access-Control-Allow-Headers"] = strings.Join(policy.AllowedHeaders, ", ")
+	} else if v := headersCarrier(r.Headers).Get("Access-Control-Request-Headers"); v != "" {
+		headers["Access-Control-Allow-Headers"] = v
+		addVaryHeader(headers, "Access-Control-Request-Headers")
+	}
+
+	if policy.MaxAge > 0 {
+		headers["Access-Control-Max-Age"] = strconv.Itoa(policy.MaxAge)
+	}
+}
+
+// addVaryHeader
+// append a header name into the "Vary" response header.
+func addVaryHeader(headers map[string]string, name string) {
+	current, ok := headers["Vary"]
+	if !ok || current == "" {
+		headers["Vary"] = name
+		return
+	}
+
+	for _, v := range strings.Split(current, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), name) {
+			return
+		}
+	}
+
+	headers["Vary"] = current + ", " + name
+}
@@ -0,0 +1,114 @@
+package bootstrap
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSetCORSHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  CORSPolicy
+		origin  string
+		code    string
+		headers map[string]string
+	}{
+		{
+			"any origin",
+			CORSPolicy{AllowedOrigins: []string{"*"}},
+			"https://a.com", "OK",
+			map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			"any origin never gets the credentials",
+			CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			"https://evil.com", "OK",
+			map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			"listed origin with credentials",
+			CORSPolicy{AllowedOrigins: []string{"https://a.com"}, AllowCredentials: true},
+			"https://a.com", "OK",
+			map[string]string{"Access-Control-Allow-Origin": "https://a.com", "Access-Control-Allow-Credentials": "true", "Vary": "Origin"},
+		},
+		{
+			"origin pattern",
+			CORSPolicy{AllowedOrigins: []string{"https://*.a.com"}},
+			"https://app.a.com", "OK",
+			map[string]string{"Access-Control-Allow-Origin": "https://app.a.com", "Vary": "Origin"},
+		},
+		{
+			"origin not allowed",
+			CORSPolicy{AllowedOrigins: []string{"https://a.com"}, AllowCredentials: true},
+			"https://evil.com", "OK",
+			map[string]string{"Vary": "Origin"},
+		},
+		{
+			"preflight",
+			CORSPolicy{AllowedOrigins: []string{"https://a.com"}, AllowedHeaders: []string{"X-Key"}, MaxAge: 60},
+			"https://a.com", "GEN-0005",
+			map[string]string{
+				"Access-Control-Allow-Origin":  "https://a.com",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "X-Key",
+				"Access-Control-Max-Age":       "60",
+				"Vary":                         "Origin",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication()
+			app.CORS = tt.policy
+			app.APIRoutes["users"] = map[string]APIResource{"GET": {}, "POST": {}}
+
+			r := &APIRequest{Path: "users", Headers: map[string]string{"Origin": tt.origin}, Result: Result{Code: tt.code}}
+
+			headers := make(map[string]string)
+			app.setCORSHeaders(r, headers)
+
+			if !reflect.DeepEqual(headers, tt.headers) {
+				t.Errorf("headers = %v, want %v", headers, tt.headers)
+			}
+		})
+	}
+}
+
+func TestWebSocketCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource *CORSPolicy
+		origin   string
+		allowed  bool
+	}{
+		{"no origin", nil, "", true},
+		{"same origin", nil, "https://api.example.com", true},
+		{"other origin without a resource policy", nil, "https://evil.com", false},
+		{"origin allowed by the resource policy", &CORSPolicy{AllowedOrigins: []string{"https://app.example.com"}}, "https://app.example.com", true},
+		{"origin not allowed by the resource policy", &CORSPolicy{AllowedOrigins: []string{"https://app.example.com"}}, "https://evil.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication()
+
+			// the application policy does not apply to the WebSockets
+			app.CORS = CORSPolicy{AllowedOrigins: []string{"*"}}
+
+			r := &APIRequest{Resource: APIResource{CORS: tt.resource}}
+
+			req := httptest.NewRequest("GET", "https://api.example.com/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			upgrader := app.webSocketUpgrader(r)
+
+			if allowed := upgrader.CheckOrigin(req); allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v", allowed, tt.allowed)
+			}
+		})
+	}
+}
@@ -20,7 +20,7 @@ var DefaultCodes = map[string]Code{
 	"GEN-0004": {HTTPCode: 404, Message: map[string]string{
 		"en-us": "This application is not configured to handle this endpoint",
 	}},
-	"GEN-0005": {HTTPCode: 202, Message: map[string]string{
+	"GEN-0005": {HTTPCode: 204, Message: map[string]string{
 		"en-us": "Performing CORS validation for the OPTIONS HTTP method",
 	}},
 	"GEN-0006": {HTTPCode: 405, Message: map[string]string{
@@ -47,4 +47,58 @@ var DefaultCodes = map[string]Code{
 	"GEN-0013": {HTTPCode: 406, Message: map[string]string{
 		"en-us": "Required parameters missing or invalid",
 	}},
+	"GEN-0014": {HTTPCode: 501, Message: map[string]string{
+		"en-us": "The requested resource uses a middleware that is not implemented",
+	}},
+	"GEN-0015": {HTTPCode: 504, Message: map[string]string{
+		"en-us": "The requested resource did not finish within its execution time limit",
+	}},
+	"GEN-0016": {HTTPCode: 499, Message: map[string]string{
+		"en-us": "The request was cancelled before the resource finished its execution",
+	}},
+	"GEN-0017": {HTTPCode: 304, Message: map[string]string{
+		"en-us": "The resource was not modified since the representation held by the client",
+	}},
+	"GEN-0018": {HTTPCode: 413, Message: map[string]string{
+		"en-us": "The request body is larger than the maximum size accepted by this resource",
+	}},
+	"GEN-0019": {HTTPCode: 400, Message: map[string]string{
+		"en-us": "The request body could not be read completely",
+	}},
+	"GEN-0020": {HTTPCode: 422, Message: map[string]string{
+		"en-us": "The 'Idempotency-Key' was already used by a request with a different payload",
+	}},
+	"GEN-0021": {HTTPCode: 409, Message: map[string]string{
+		"en-us": "A request with the same 'Idempotency-Key' is still being processed",
+	}},
+	"GEN-0022": {HTTPCode: 400, Message: map[string]string{
+		"en-us": "This resource requires an 'Idempotency-Key' header of up to 255 characters",
+	}},
+	"GEN-0023": {HTTPCode: 503, Message: map[string]string{
+		"en-us": "The idempotency of the request could not be ensured. Try again later",
+	}},
+	"GEN-0024": {HTTPCode: 400, Message: map[string]string{
+		"en-us": "The batch must hold at least one and up to the maximum amount of sub-requests",
+	}},
+	"GEN-0025": {HTTPCode: 400, Message: map[string]string{
+		"en-us": "Batch requests can not be called within another batch",
+	}},
+	"GEN-0026": {HTTPCode: 400, Message: map[string]string{
+		"en-us": "The pagination cursor is invalid or was not issued by this application",
+	}},
+	"GEN-0027": {HTTPCode: 400, Message: map[string]string{
+		"en-us": "The requested fields are not available at this resource",
+	}},
+	"GEN-0028": {HTTPCode: 501, Message: map[string]string{
+		"en-us": "This resource streams its response, which is not supported by the current adapter",
+	}},
+	"GEN-0029": {HTTPCode: 426, Message: map[string]string{
+		"en-us": "This resource must be called with a WebSocket connection upgrade",
+	}},
+	"GEN-0030": {HTTPCode: 400, Message: map[string]string{
+		"en-us": "The request parameters could not be bound to the resource method input",
+	}},
+	"GEN-0031": {HTTPCode: 403, Message: map[string]string{
+		"en-us": "The WebSocket connection is no longer registered for this route and user",
+	}},
 }
@@ -0,0 +1,217 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// APIResourceFields
+// define the sparse fieldsets of a resource, selected by the "fields" query parameter.
+type APIResourceFields struct {
+	Allowed []string `json:"allowed"` // selectable fields (dot paths), their children included. empty for any
+}
+
+// fieldSelection
+// define the selected fields of an object, with the selection of their children.
+// a field with an empty selection is kept whole.
+type fieldSelection map[string]fieldSelection
+
+// parseFieldSelection
+// parse the "fields" query parameter of a resource with sparse fieldsets,
+// checking the requested fields against the allowed ones. the fields are
+// named as at the JSON representation of the data, whatever the response format.
+func (r *APIRequest) parseFieldSelection() {
+	if r.Result.Code != "OK" || r.Resource.Fields == nil || r.Query["fields"] == "" {
+		return
+	}
+
+	selection := make(fieldSelection)
+
+	var disallowed []string
+
+	for _, path := range strings.Split(r.Query["fields"], ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		if !r.Resource.Fields.allows(path) {
+			disallowed = append(disallowed, path)
+			continue
+		}
+
+		selection.add(strings.Split(path, "."))
+	}
+
+	if len(disallowed) > 0 {
+		r.Logger.Info("the requested fields are not allowed at this resource", "fields", disallowed)
+
+		r.updateResult("GEN-0027", disallowed)
+		return
+	}
+
+	r.fields = selection
+
+	r.Logger.Debug("parsed the selected fields", "fields", r.Query["fields"])
+
+}
+
+// selectFields
+// prune the result data to the selected fields, before it is encoded
+// by the response encoder.
+func (r *APIRequest) selectFields() {
+	if r.Result.Code != "OK" || len(r.fields) == 0 {
+		return
+	}
+
+	// convert the data into its generic (serialized) representation
+	b, err := json.Marshal(r.Result.Data)
+	if err != nil {
+		r.Logger.Warn("failed to serialize the result data for the fields selection", "err", err)
+		return
+	}
+
+	var data interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&data); err != nil {
+		r.Logger.Warn("failed to parse the result data for the fields selection", "err", err)
+		return
+	}
+
+	r.Result.Data = selectedFields{r.fields.prune(data)}
+
+}
+
+// allows
+// check if the field path or one of its parents is allowed.
+func (f *APIResourceFields) allows(path string) bool {
+	if len(f.Allowed) == 0 {
+		return true
+	}
+
+	for _, v := range f.Allowed {
+		if path == v || strings.HasPrefix(path, v+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// add
+// add a field path into the selection.
+func (s fieldSelection) add(path []string) {
+	child, ok := s[path[0]]
+
+	// the whole field is already selected
+	if ok && len(child) == 0 {
+		return
+	}
+
+	if len(path) == 1 {
+		s[path[0]] = fieldSelection{}
+		return
+	}
+
+	if !ok {
+		child = make(fieldSelection)
+		s[path[0]] = child
+	}
+
+	child.add(path[1:])
+}
+
+// prune
+// return the generic data with only the selected fields of its objects.
+// the selection is applied to each item of the arrays.
+func (s fieldSelection) prune(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		pruned := make(map[string]interface{})
+
+		for k, child := range s {
+			if value, ok := v[k]; ok {
+				if len(child) == 0 {
+					pruned[k] = value
+				} else {
+					pruned[k] = child.prune(value)
+				}
+			}
+		}
+
+		return pruned
+	case []interface{}:
+		pruned := make([]interface{}, len(v))
+		for i, item := range v {
+			pruned[i] = s.prune(item)
+		}
+
+		return pruned
+	}
+
+	return data
+}
+
+// selectedFields
+// hold the pruned data of a result, marshaling it for the JSON and XML responses.
+type selectedFields struct {
+	value interface{}
+}
+
+// MarshalJSON
+// marshal the pruned data as JSON.
+func (d selectedFields) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.value)
+}
+
+// MarshalXML
+// marshal the pruned data as XML, with an element per object field (sorted)
+// and the array items repeating the element of the array.
+func (d selectedFields) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalGenericXML(e, d.value, start)
+}
+
+// marshalGenericXML
+// encode a generic (JSON decoded) value as XML within the start element.
+func marshalGenericXML(e *xml.Encoder, value interface{}, start xml.StartElement) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := marshalGenericXML(e, v[k], xml.StartElement{Name: xml.Name{Local: k}}); err != nil {
+				return err
+			}
+		}
+
+		return e.EncodeToken(start.End())
+	case []interface{}:
+		for _, item := range v {
+			if err := marshalGenericXML(e, item, start); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return e.EncodeElement(fmt.Sprint(value), start)
+}
@@ -0,0 +1,80 @@
+package bootstrap
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestFieldSelection(t *testing.T) {
+	app := newTestApplication()
+
+	type address struct {
+		City    string `json:"city"`
+		Country string `json:"country"`
+	}
+
+	type user struct {
+		ID      int     `json:"id"`
+		Name    string  `json:"name"`
+		Email   string  `json:"email"`
+		Address address `json:"address"`
+	}
+
+	app.Route("users").GET(func(r *APIRequest) Result {
+		return Result{"OK", []user{
+			{1, "Ana", "ana@a.com", address{"Lisbon", "PT"}},
+			{2, "Bia", "bia@a.com", address{"Porto", "PT"}},
+		}}
+	}).Fields("id", "name", "address")
+
+	tests := []struct {
+		name   string
+		fields string
+		code   string
+		data   string
+	}{
+		{"all fields", "", "OK", `[{"id":1,"name":"Ana","email":"ana@a.com","address":{"city":"Lisbon","country":"PT"}},{"id":2,"name":"Bia","email":"bia@a.com","address":{"city":"Porto","country":"PT"}}]`},
+		{"selected fields", "id,address.city", "OK", `[{"address":{"city":"Lisbon"},"id":1},{"address":{"city":"Porto"},"id":2}]`},
+		{"whole object selected", "address,address.city", "OK", `[{"address":{"city":"Lisbon","country":"PT"}},{"address":{"city":"Porto","country":"PT"}}]`},
+		{"field not allowed", "email", "GEN-0027", `["email"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serveTestRequest(t, app, "GET", "/users?fields="+tt.fields, "", nil)
+
+			if res.Code != tt.code {
+				t.Fatalf("code = %v (%s), want %v", res.Code, res.Data, tt.code)
+			}
+
+			if string(res.Data) != tt.data {
+				t.Errorf("data = %s, want %v", res.Data, tt.data)
+			}
+		})
+	}
+}
+
+func TestSelectedFieldsXML(t *testing.T) {
+	r := APIRequest{
+		Result: Result{"OK", []map[string]interface{}{
+			{"id": 1, "name": "Ana", "address": map[string]string{"city": "Lisbon", "country": "PT"}},
+			{"id": 2, "name": "Bia", "address": map[string]string{"city": "Porto", "country": "PT"}},
+		}},
+		fields: fieldSelection{"id": {}, "address": {"city": {}}},
+	}
+
+	r.selectFields()
+
+	b, err := xml.Marshal(struct {
+		XMLName xml.Name    `xml:"response"`
+		Data    interface{} `xml:"data"`
+	}{Data: r.Result.Data})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<response><data><address><city>Lisbon</city></address><id>1</id></data><data><address><city>Porto</city></address><id>2</id></data></response>`
+	if string(b) != want {
+		t.Errorf("XML = %s, want %s", b, want)
+	}
+}
@@ -0,0 +1,226 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// HealthSettings
+// define the built-in liveness, readiness and version routes. they are answered
+// before the API pipeline, out of the access logs, metrics and authentication.
+// they can not collide with the API routes (as the metrics route), which is
+// checked by ListenAndServe, the routes registered from the code and the reloads.
+type HealthSettings struct {
+	LivenessRoute  string                 // path of the liveness route (ex: "healthz"), empty to disable it
+	ReadinessRoute string                 // path of the readiness route (ex: "readyz"), empty to disable it
+	VersionRoute   string                 // path of the version route (ex: "version"), empty to disable it
+	Checks         map[string]HealthCheck // dependency checks of the readiness route, by name
+	Timeout        time.Duration          // max duration of each check, default = 2s
+}
+
+// HealthCheck
+// check if a dependency of the application is available.
+type HealthCheck func(ctx context.Context) error
+
+// healthCheckResult
+// define the outcome of a dependency check at the readiness route.
+type healthCheckResult struct {
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration_ms"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// DynamoTableCheck
+// return a check that describes the DynamoDB table.
+func DynamoTableCheck(table string) HealthCheck {
+	return func(ctx context.Context) error {
+		_, err := DB.Table(table).Describe().RunWithContext(ctx)
+		return err
+	}
+}
+
+// SQSQueueCheck
+// return a check that fetches the attributes of the SQS queue.
+func SQSQueueCheck(url string) HealthCheck {
+	return func(ctx context.Context) error {
+		_, err := QUEUE.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+			QueueUrl:       aws.String(url),
+			AttributeNames: []*string{aws.String("QueueArn")},
+		})
+
+		return err
+	}
+}
+
+// serveHealthRoute
+// answer the request if it is for one of the built-in health routes.
+func (app *Application) serveHealthRoute(r *APIRequest) (res APIResponse, ok bool) {
+	s := app.HealthSettings
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return
+	}
+
+	var code int
+	var body interface{}
+
+	switch {
+	case s.LivenessRoute != "" && r.Path == s.LivenessRoute:
+		code, body = http.StatusOK, map[string]string{"status": "ok"}
+	case s.ReadinessRoute != "" && r.Path == s.ReadinessRoute:
+		code, body = app.checkReadiness(r.Context)
+	case s.VersionRoute != "" && r.Path == s.VersionRoute:
+		code, body = http.StatusOK, app.versionInfo()
+	default:
+		return
+	}
+
+	content, _ := json.Marshal(body)
+	if r.Method == "HEAD" {
+		content = []byte{}
+	}
+
+	return APIResponse{
+		HTTPCode: code,
+		Content:  content,
+		Headers: map[string]string{
+			"Content-Type":  "application/json; charset=utf-8",
+			"Cache-Control": "no-store",
+			"x-request-id":  r.ID,
+		},
+	}, true
+}
+
+// checkBuiltinRoutes
+// return an error when a built-in health or metrics route is also an API
+// route, as it would never reach the API pipeline.
+func (app *Application) checkBuiltinRoutes(routes map[string]map[string]APIResource) error {
+	s := app.HealthSettings
+
+	for _, route := range []string{s.LivenessRoute, s.ReadinessRoute, s.VersionRoute} {
+		if _, ok := routes[route]; route != "" && ok {
+			return fmt.Errorf("the health route %v is also an API route", route)
+		}
+	}
+
+	if route := app.MetricsSettings.Route; route != "" && app.MetricsSettings.Address == "" {
+		if _, ok := routes[route]; ok {
+			return fmt.Errorf("the metrics route %v is also an API route", route)
+		}
+	}
+
+	return nil
+}
+
+// checkReadiness
+// run the dependency checks concurrently, each one within the timeout.
+// internal error details are only returned in debug mode.
+func (app *Application) checkReadiness(ctx context.Context) (code int, body interface{}) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	timeout := app.HealthSettings.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Second
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	status := "ok"
+	results := make(map[string]healthCheckResult)
+
+	for name, check := range app.HealthSettings.Checks {
+		wg.Add(1)
+
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			started := time.Now()
+			err := runHealthCheck(checkCtx, check)
+
+			result := healthCheckResult{Status: "ok", Duration: durationMilliseconds(time.Since(started))}
+
+			if err != nil {
+				app.Logger.Warn("readiness check failed", "check", name, "err", err)
+
+				result.Status = "fail"
+				if app.DebugMode {
+					result.Error = err.Error()
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			results[name] = result
+			if err != nil {
+				status = "fail"
+			}
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	code = http.StatusOK
+	if status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+
+	return code, map[string]interface{}{"status": status, "checks": results}
+}
+
+// runHealthCheck
+// call the check, stopping at the context deadline even if the check
+// does not observe it and handling its panics.
+func runHealthCheck(ctx context.Context, check HealthCheck) (err error) {
+	done := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				done <- fmt.Errorf("health check panic [recover: %v]", rcv)
+			}
+		}()
+
+		done <- check(ctx)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	return
+}
+
+// versionInfo
+// return the version, build and instance information of the application.
+func (app *Application) versionInfo() map[string]interface{} {
+	isFly, region := IsFlyioInstance()
+	if !isFly {
+		region = os.Getenv("APP_REGION")
+	}
+
+	return map[string]interface{}{
+		"version":    app.Version,
+		"build_id":   app.BuildID,
+		"go_version": runtime.Version(),
+		"flyio":      isFly,
+		"region":     region,
+	}
+}
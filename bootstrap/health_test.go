@@ -0,0 +1,167 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// denyBackend
+// refuse the authorization of every request.
+type denyBackend struct{ testBackend }
+
+func (denyBackend) APIAuthorizeUser(r *APIRequest) Result { return Result{Code: "GEN-0008"} }
+
+func TestHealthRoutes(t *testing.T) {
+	blocked := make(chan struct{})
+	defer close(blocked)
+
+	checks := map[string]map[string]HealthCheck{
+		"passing": {"db": func(ctx context.Context) error { return nil }},
+		"failing": {"db": func(ctx context.Context) error { return nil }, "queue": func(ctx context.Context) error { return errors.New("queue unavailable") }},
+		"panic":   {"db": func(ctx context.Context) error { panic("check failure") }},
+		"timeout": {"db": func(ctx context.Context) error { <-blocked; return nil }},
+	}
+
+	tests := []struct {
+		name   string
+		checks string
+		method string
+		target string
+		status int
+		body   string
+	}{
+		{"liveness", "", "GET", "/healthz", http.StatusOK, `{"status":"ok"}`},
+		{"liveness HEAD", "", "HEAD", "/healthz", http.StatusOK, ""},
+		{"readiness without checks", "", "GET", "/readyz", http.StatusOK, `{"checks":{},"status":"ok"}`},
+		{"readiness passing", "passing", "GET", "/readyz", http.StatusOK, ""},
+		{"readiness failing", "failing", "GET", "/readyz", http.StatusServiceUnavailable, ""},
+		{"readiness panicking", "panic", "GET", "/readyz", http.StatusServiceUnavailable, ""},
+		{"readiness timed out", "timeout", "GET", "/readyz", http.StatusServiceUnavailable, ""},
+		{"readiness HEAD", "failing", "HEAD", "/readyz", http.StatusServiceUnavailable, ""},
+		{"version", "", "GET", "/version", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var accessLog bytes.Buffer
+
+			app := newTestApplication()
+			app.Backend = denyBackend{}
+			app.Version, app.BuildID = "1.2.3", "abc"
+			app.AccessLogSettings = AccessLogSettings{Writer: &accessLog}
+			app.HealthSettings = HealthSettings{
+				LivenessRoute:  "healthz",
+				ReadinessRoute: "readyz",
+				VersionRoute:   "version",
+				Checks:         checks[tt.checks],
+				Timeout:        20 * time.Millisecond,
+			}
+
+			w := httptest.NewRecorder()
+			app.APIHTTPHandler(w, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %v (%s), want %v", w.Code, w.Body, tt.status)
+			}
+
+			if w.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", w.Header().Get("Cache-Control"))
+			}
+
+			// the probes are not authorized nor written to the access log
+			if accessLog.Len() > 0 {
+				t.Errorf("access log = %q, want empty", accessLog.String())
+			}
+
+			if tt.method == "HEAD" {
+				if w.Body.Len() > 0 {
+					t.Errorf("HEAD body = %q, want empty", w.Body)
+				}
+
+				return
+			}
+
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %s, want %s", w.Body, tt.body)
+			}
+		})
+	}
+}
+
+func TestHealthReadinessChecks(t *testing.T) {
+	app := newTestApplication()
+	app.HealthSettings = HealthSettings{
+		ReadinessRoute: "readyz",
+		Checks: map[string]HealthCheck{
+			"db":    func(ctx context.Context) error { return nil },
+			"queue": func(ctx context.Context) error { return errors.New("queue unavailable") },
+		},
+	}
+
+	for _, debug := range []bool{false, true} {
+		app.DebugMode = debug
+
+		code, body := app.checkReadiness(context.Background())
+		if code != http.StatusServiceUnavailable {
+			t.Fatalf("code = %v, want %v", code, http.StatusServiceUnavailable)
+		}
+
+		results := body.(map[string]interface{})["checks"].(map[string]healthCheckResult)
+
+		if results["db"].Status != "ok" || results["queue"].Status != "fail" {
+			t.Errorf("results = %+v", results)
+		}
+
+		// the internal error details are only returned in debug mode
+		if want := map[bool]string{false: "", true: "queue unavailable"}[debug]; results["queue"].Error != want {
+			t.Errorf("debug %v error = %q, want %q", debug, results["queue"].Error, want)
+		}
+	}
+}
+
+func TestHealthVersion(t *testing.T) {
+	app := newTestApplication()
+	app.Version, app.BuildID = "1.2.3", "abc"
+	app.HealthSettings = HealthSettings{VersionRoute: "version"}
+
+	w := httptest.NewRecorder()
+	app.APIHTTPHandler(w, httptest.NewRequest("GET", "/version", nil))
+
+	var info map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+
+	if info["version"] != "1.2.3" || info["build_id"] != "abc" || info["go_version"] == "" {
+		t.Errorf("version = %v", info)
+	}
+}
+
+func TestHealthRoutesOnlyAnswerGET(t *testing.T) {
+	app := newTestApplication()
+	app.HealthSettings = HealthSettings{LivenessRoute: "healthz"}
+
+	// other methods reach the API pipeline, without the route
+	if res := serveTestRequest(t, app, "POST", "/healthz", "", nil); res.Code != "GEN-0004" {
+		t.Errorf("POST code = %v, want GEN-0004", res.Code)
+	}
+}
+
+func TestCheckHealthRoutes(t *testing.T) {
+	app := newTestApplication()
+	app.HealthSettings = HealthSettings{LivenessRoute: "healthz", ReadinessRoute: "readyz"}
+
+	if err := app.checkBuiltinRoutes(map[string]map[string]APIResource{"users": {}}); err != nil {
+		t.Errorf("checkBuiltinRoutes() = %v, want nil", err)
+	}
+
+	if err := app.checkBuiltinRoutes(map[string]map[string]APIResource{"readyz": {}}); err == nil {
+		t.Error("checkBuiltinRoutes() = nil, want the collision error")
+	}
+}
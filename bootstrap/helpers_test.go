@@ -0,0 +1,83 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testBackend
+// authorize every request and keep the method results.
+type testBackend struct{}
+
+func (testBackend) APIAuthorizeUser(r *APIRequest) Result          { return Result{Code: "OK"} }
+func (testBackend) APIBeforeMethodOperations(r *APIRequest) Result { return Result{Code: "OK"} }
+func (testBackend) APIAfterMethodOperations(r *APIRequest) Result  { return r.Result }
+
+// newTestApplication
+// return an application with the default codes and no routes, logging nowhere.
+func newTestApplication() *Application {
+	app := &Application{
+		Codes:         make(map[string]Code),
+		Backend:       testBackend{},
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		APILogsWriter: io.Discard,
+		APIRoutes:     make(map[string]map[string]APIResource),
+		APIMethods:    make(map[string]APIResourceMethod),
+		configMu:      &sync.RWMutex{},
+	}
+
+	for k, v := range DefaultCodes {
+		app.Codes[k] = v
+	}
+
+	return app
+}
+
+// testResponse
+// define an answered test request with its decoded envelope.
+type testResponse struct {
+	Status  int
+	Code    string
+	Meta    APIMetadata
+	Data    json.RawMessage
+	Headers map[string]string
+}
+
+// serveTestRequest
+// serve a request through the net/http handler, decoding its response envelope.
+func serveTestRequest(t *testing.T, app *Application, method, target, body string, headers map[string]string) testResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	w := httptest.NewRecorder()
+	app.APIHTTPHandler(w, req)
+
+	res := testResponse{Status: w.Code, Headers: make(map[string]string)}
+	for k := range w.Header() {
+		res.Headers[k] = w.Header().Get(k)
+	}
+
+	var envelope struct {
+		Meta APIMetadata     `json:"meta"`
+		Data json.RawMessage `json:"data"`
+	}
+
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("failed to decode the response %q: %v", w.Body.String(), err)
+		}
+	}
+
+	res.Code, res.Meta, res.Data = envelope.Meta.Code, envelope.Meta, envelope.Data
+
+	return res
+}
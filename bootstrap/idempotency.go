@@ -0,0 +1,428 @@
+package bootstrap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guregu/dynamo"
+	"github.com/ncastellani/partida/utilfunc"
+)
+
+// max length of the "Idempotency-Key" header values
+const maxIdempotencyKeyLength = 255
+
+// methods that do not change the server state and never replay responses
+var safeMethods = []string{"GET", "HEAD", "OPTIONS"}
+
+// IdempotencySettings
+// define where the responses of the idempotent requests are stored and for how long.
+type IdempotencySettings struct {
+	Store   IdempotencyStore // storage of the idempotency records, nil to disable the replays
+	TTL     time.Duration    // time that a response can be replayed for, default = 24h
+	LockTTL time.Duration    // time that a key is held by a request in progress, default = 1m
+}
+
+// APIResourceIdempotency
+// define the idempotency policy of a resource with unsafe methods.
+type APIResourceIdempotency struct {
+	Required bool `json:"required"` // if the "Idempotency-Key" header must be sent
+	TTL      int  `json:"ttl"`      // seconds that a response can be replayed for, overriding the application one
+}
+
+// IdentifiedUser
+// define an authorized user (as set by the backend at r.User) with a stable
+// identifier, which scopes its idempotency keys across its tokens.
+type IdentifiedUser interface {
+	UserID() string
+}
+
+// IdempotencyRecord
+// define the state of an idempotency key: the fingerprint of the request
+// that reserved it and, once completed, the response to be replayed.
+type IdempotencyRecord struct {
+	Key         string            `dynamo:"key,hash"`    // hash of the idempotency key and the identity that sent it
+	Fingerprint string            `dynamo:"fingerprint"` // hash of the request method, path, identity and body
+	Completed   bool              `dynamo:"completed"`   // if the request finished and its response is stored
+	Code        string            `dynamo:"code"`        // result code of the response
+	HTTPCode    int               `dynamo:"http_code"`
+	Headers     map[string]string `dynamo:"headers"`
+	Content     []byte            `dynamo:"content"`
+	ExpiresAt   time.Time         `dynamo:"expires_at,unixtime"` // expiration of the lock or of the response (DynamoDB TTL attribute)
+}
+
+// IdempotencyStore
+// define a storage of idempotency records shared by the application instances.
+type IdempotencyStore interface {
+
+	// Reserve
+	// atomically create the record if its key is not held by an unexpired one.
+	// return the existing record when the key is already held.
+	Reserve(ctx context.Context, record IdempotencyRecord) (existing *IdempotencyRecord, err error)
+
+	// Complete
+	// store the completed record with the response to be replayed.
+	Complete(ctx context.Context, record IdempotencyRecord) error
+
+	// Release
+	// delete the record of the key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// idempotency
+// replay the stored response of requests that reuse an "Idempotency-Key" on the
+// resources that opt into it, instead of calling the resource method again.
+func (app *Application) idempotency(next APIHandler) APIHandler {
+	return func(r *APIRequest) {
+		policy := r.Resource.Idempotency
+		if policy == nil || utilfunc.StringInSlice(r.Method, safeMethods) {
+			next(r)
+			return
+		}
+
+		// check the idempotency key of the request
+		key := headersCarrier(r.Headers).Get("Idempotency-Key")
+
+		if key == "" && !policy.Required {
+			next(r)
+			return
+		}
+
+		if key == "" || len(key) > maxIdempotencyKeyLength {
+			r.Logger.Info("request does not hold a valid idempotency key", "length", len(key))
+
+			r.updateResult("GEN-0022", maxIdempotencyKeyLength)
+			return
+		}
+
+		store := app.IdempotencySettings.Store
+		if store == nil {
+			r.Logger.Error("the resource requires idempotency but the application has no idempotency store")
+
+			r.updateResult("GEN-0023", utilfunc.Empty)
+			return
+		}
+
+		// scope the key by the identity that sent it, so an user can not
+		// replay or block the responses of the keys used by the others
+		scope, ok := r.idempotencyScope()
+		if !ok {
+			r.Logger.Error("the idempotency key can not be scoped as the authorized identity is unknown")
+
+			r.updateResult("GEN-0023", utilfunc.Empty)
+			return
+		}
+
+		r.Logger = r.Logger.With("idempotency_key", key)
+
+		// the store calls must outlive a cancelled or timed out request
+		ctx := context.WithoutCancel(r.Context)
+
+		// reserve the key for this request
+		record := IdempotencyRecord{
+			Key:         idempotencyStoreKey(scope, key),
+			Fingerprint: r.idempotencyFingerprint(scope),
+			ExpiresAt:   time.Now().Add(app.idempotencyLockTTL()),
+		}
+
+		existing, err := store.Reserve(ctx, record)
+		if err != nil {
+			r.Logger.Error("failed to reserve the idempotency key", "err", err)
+
+			r.updateResult("GEN-0023", utilfunc.Empty)
+			return
+		}
+
+		if existing != nil {
+			app.replayIdempotentRequest(r, existing, record.Fingerprint)
+			return
+		}
+
+		r.Logger.Debug("reserved the idempotency key for this request")
+
+		// release the key if the rest of the pipeline panics, allowing the
+		// request to be retried (the panic is recovered by the pipeline)
+		returned := false
+
+		defer func() {
+			if returned {
+				return
+			}
+
+			if err := store.Release(ctx, record.Key); err != nil {
+				r.Logger.Warn("failed to release the idempotency key", "err", err)
+			}
+		}()
+
+		// call the rest of the pipeline and assemble the response to store it
+		next(r)
+
+		returned = true
+
+		r.Response = r.makeResponse(app)
+
+		// server failures are not stored, allowing the request to be retried. a
+		// method abandoned by its timeout may still be running, so its key is
+		// only released once it returns (or once the reservation expires)
+		if r.Response.HTTPCode >= 500 {
+			release := func() {
+				if err := store.Release(ctx, record.Key); err != nil {
+					r.Logger.Warn("failed to release the idempotency key", "err", err)
+				}
+			}
+
+			if r.abandoned != nil {
+				go func(abandoned <-chan struct{}) {
+					<-abandoned
+					release()
+				}(r.abandoned)
+
+				return
+			}
+
+			release()
+			return
+		}
+
+		record.Completed = true
+		record.Code = r.Result.Code
+		record.HTTPCode = r.Response.HTTPCode
+		record.Content = r.Response.Content
+		record.Headers = make(map[string]string)
+		record.ExpiresAt = time.Now().Add(app.idempotencyTTL(policy))
+
+		// the cross-origin headers depend on the origin of each request
+		for k, v := range r.Response.Headers {
+			if k != "Vary" && !strings.HasPrefix(k, "Access-Control-") {
+				record.Headers[k] = v
+			}
+		}
+
+		if err := store.Complete(ctx, record); err != nil {
+			r.Logger.Warn("failed to store the response of the idempotency key", "err", err)
+		}
+
+	}
+}
+
+// replayIdempotentRequest
+// answer a request whose idempotency key is held by an existing record.
+func (app *Application) replayIdempotentRequest(r *APIRequest, existing *IdempotencyRecord, fingerprint string) {
+	if existing.Fingerprint != fingerprint {
+		r.Logger.Info("idempotency key was used by a different request")
+
+		r.updateResult("GEN-0020", utilfunc.Empty)
+		return
+	}
+
+	if !existing.Completed {
+		r.Logger.Info("idempotency key is held by a request in progress")
+
+		r.updateResult("GEN-0021", utilfunc.Empty)
+		return
+	}
+
+	r.Logger.Info("replaying the stored response of the idempotency key", "code", existing.Code)
+
+	headers := map[string]string{"Idempotent-Replayed": "true"}
+	for k, v := range existing.Headers {
+		headers[k] = v
+	}
+
+	app.setCORSHeaders(r, headers)
+
+	r.Result = Result{Code: existing.Code, Data: utilfunc.Empty}
+	r.Response = APIResponse{HTTPCode: existing.HTTPCode, Content: existing.Content, Headers: headers}
+}
+
+// idempotencyFingerprint
+// return the hash of the request method, path, identity scope and body.
+func (r *APIRequest) idempotencyFingerprint(scope string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%v\n%v\n%v\n", r.Method, r.Path, scope)
+	h.Write(r.Input)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyScope
+// return the identity that scopes the idempotency keys of the request: the
+// ID of an IdentifiedUser or the hash of the authentication token. the
+// requests without a token share a public scope, unless the resource
+// requires the authentication.
+func (r *APIRequest) idempotencyScope() (scope string, ok bool) {
+	if user, isIdentified := r.User.(IdentifiedUser); isIdentified && user.UserID() != "" {
+		return "user:" + user.UserID(), true
+	}
+
+	if r.ExtractedToken != "" {
+		h := sha256.Sum256([]byte(r.ExtractedToken))
+		return "token:" + hex.EncodeToString(h[:]), true
+	}
+
+	if r.Resource.Authentication {
+		return "", false
+	}
+
+	return "public", true
+}
+
+// idempotencyStoreKey
+// return the key of the idempotency record of a key sent by an identity.
+func idempotencyStoreKey(scope, key string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%v\n%v", scope, key)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyTTL
+// return the time that the responses of the resource can be replayed for.
+func (app *Application) idempotencyTTL(policy *APIResourceIdempotency) time.Duration {
+	if policy.TTL > 0 {
+		return time.Duration(policy.TTL) * time.Second
+	}
+
+	if app.IdempotencySettings.TTL > 0 {
+		return app.IdempotencySettings.TTL
+	}
+
+	return 24 * time.Hour
+}
+
+// idempotencyLockTTL
+// return the time that a key is held by a request in progress.
+func (app *Application) idempotencyLockTTL() time.Duration {
+	if app.IdempotencySettings.LockTTL > 0 {
+		return app.IdempotencySettings.LockTTL
+	}
+
+	return time.Minute
+}
+
+// MemoryIdempotencyStore
+// store the idempotency records in the memory of the current instance.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]IdempotencyRecord
+	lastSweep time.Time
+}
+
+// NewMemoryIdempotencyStore
+// return an empty in-memory idempotency store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]IdempotencyRecord)}
+}
+
+// Reserve
+// create the record if its key is not held by an unexpired one.
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	// remove the expired records once a minute
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, v := range s.records {
+			if now.After(v.ExpiresAt) {
+				delete(s.records, k)
+			}
+		}
+
+		s.lastSweep = now
+	}
+
+	if existing, ok := s.records[record.Key]; ok && now.Before(existing.ExpiresAt) {
+		return &existing, nil
+	}
+
+	s.records[record.Key] = record
+
+	return nil, nil
+}
+
+// Complete
+// store the completed record.
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[record.Key] = record
+
+	return nil
+}
+
+// Release
+// delete the record of the key.
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return nil
+}
+
+// DynamoIdempotencyStore
+// store the idempotency records at a DynamoDB table (using the DB session), with
+// the "key" string hash key and the "expires_at" attribute enabled as its TTL.
+type DynamoIdempotencyStore struct {
+	Table string
+}
+
+// NewDynamoIdempotencyStore
+// return an idempotency store for the passed DynamoDB table.
+func NewDynamoIdempotencyStore(table string) *DynamoIdempotencyStore {
+	return &DynamoIdempotencyStore{Table: table}
+}
+
+// Reserve
+// conditionally put the record if its key is absent or expired (DynamoDB
+// deletes the expired items lazily), fetching the existing one otherwise.
+func (s *DynamoIdempotencyStore) Reserve(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error) {
+	table := DB.Table(s.Table)
+
+	err := table.Put(record).
+		If("attribute_not_exists($) OR $ < ?", "key", "expires_at", time.Now().Unix()).
+		RunWithContext(ctx)
+
+	if err == nil {
+		return nil, nil
+	}
+
+	if !dynamo.IsCondCheckFailed(err) {
+		return nil, err
+	}
+
+	// fetch the record that holds the key
+	var existing IdempotencyRecord
+
+	err = table.Get("key", record.Key).Consistent(true).OneWithContext(ctx, &existing)
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return nil, fmt.Errorf("idempotency record was deleted while reserved [key: %v]", record.Key)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &existing, nil
+}
+
+// Complete
+// put the completed record.
+func (s *DynamoIdempotencyStore) Complete(ctx context.Context, record IdempotencyRecord) error {
+	return DB.Table(s.Table).Put(record).RunWithContext(ctx)
+}
+
+// Release
+// delete the record of the key.
+func (s *DynamoIdempotencyStore) Release(ctx context.Context, key string) error {
+	return DB.Table(s.Table).Delete("key", key).RunWithContext(ctx)
+}
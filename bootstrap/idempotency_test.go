@@ -0,0 +1,239 @@
+package bootstrap
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// identifiedTestUser
+// define an user with a stable identifier across its tokens.
+type identifiedTestUser string
+
+func (u identifiedTestUser) UserID() string { return string(u) }
+
+// tokenUserBackend
+// authorize the requests with the user named after the token suffix.
+type tokenUserBackend struct{ testBackend }
+
+func (tokenUserBackend) APIAuthorizeUser(r *APIRequest) Result {
+	r.User = identifiedTestUser(r.ExtractedToken[len(r.ExtractedToken)-1:])
+	return Result{Code: "OK"}
+}
+
+// panicBeforeMethodBackend
+// panic before the resource method is called.
+type panicBeforeMethodBackend struct{ testBackend }
+
+func (panicBeforeMethodBackend) APIBeforeMethodOperations(r *APIRequest) Result {
+	panic("before method failure")
+}
+
+func TestIdempotency(t *testing.T) {
+	app := newTestApplication()
+	app.IdempotencySettings.Store = NewMemoryIdempotencyStore()
+
+	calls := 0
+
+	app.APIRoutes["payments"] = map[string]APIResource{
+		"POST": {ResourceMethod: "pay", Idempotency: &APIResourceIdempotency{Required: true}},
+	}
+
+	app.APIRoutes["orders"] = map[string]APIResource{
+		"POST": {ResourceMethod: "pay", Authentication: true, Idempotency: &APIResourceIdempotency{}},
+	}
+
+	app.APIMethods["pay"] = func(r *APIRequest) Result {
+		calls++
+		return Result{"OK", calls}
+	}
+
+	headers := func(token, key string) map[string]string {
+		h := map[string]string{"Content-Type": "application/json"}
+		if token != "" {
+			h["Authorization"] = "Bearer " + token
+		}
+
+		if key != "" {
+			h["Idempotency-Key"] = key
+		}
+
+		return h
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		token    string
+		key      string
+		body     string
+		code     string
+		calls    int
+		replayed bool
+	}{
+		{"key is required", "/payments", "", "", "{}", "GEN-0022", 0, false},
+		{"first request", "/payments", "", "k1", "{}", "OK", 1, false},
+		{"replayed response", "/payments", "", "k1", "{}", "OK", 1, true},
+		{"key reused with another payload", "/payments", "", "k1", `{"a":1}`, "GEN-0020", 1, false},
+		{"first request of an user", "/orders", "token-a", "k2", "{}", "OK", 2, false},
+		{"same user with another token", "/orders", "other-a", "k2", "{}", "OK", 2, true},
+		{"other user with the same key", "/orders", "token-b", "k2", "{}", "OK", 3, false},
+		{"other user with another payload", "/orders", "token-c", "k2", `{"a":1}`, "OK", 4, false},
+	}
+
+	app.Backend = tokenUserBackend{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serveTestRequest(t, app, "POST", tt.target, tt.body, headers(tt.token, tt.key))
+
+			if res.Code != tt.code {
+				t.Fatalf("code = %v, want %v", res.Code, tt.code)
+			}
+
+			if calls != tt.calls {
+				t.Errorf("method calls = %v, want %v", calls, tt.calls)
+			}
+
+			if replayed := res.Headers["Idempotent-Replayed"] == "true"; replayed != tt.replayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.replayed)
+			}
+		})
+	}
+}
+
+func TestIdempotencyReleasedOnPanic(t *testing.T) {
+	app := newTestApplication()
+	app.IdempotencySettings.Store = NewMemoryIdempotencyStore()
+
+	app.APIRoutes["payments"] = map[string]APIResource{
+		"POST": {ResourceMethod: "pay", Idempotency: &APIResourceIdempotency{}},
+	}
+
+	app.APIMethods["pay"] = func(r *APIRequest) Result { return Result{"OK", "paid"} }
+
+	headers := map[string]string{"Content-Type": "application/json", "Idempotency-Key": "k1"}
+
+	app.Backend = panicBeforeMethodBackend{}
+
+	if res := serveTestRequest(t, app, "POST", "/payments", "{}", headers); res.Status != http.StatusInternalServerError {
+		t.Fatalf("status = %v, want %v", res.Status, http.StatusInternalServerError)
+	}
+
+	app.Backend = testBackend{}
+
+	if res := serveTestRequest(t, app, "POST", "/payments", "{}", headers); res.Code != "OK" || res.Headers["Idempotent-Replayed"] != "" {
+		t.Errorf("retry = %v (replayed %q), want a new OK response", res.Code, res.Headers["Idempotent-Replayed"])
+	}
+}
+
+func TestIdempotencyHeldByTimedOutMethod(t *testing.T) {
+	app := newTestApplication()
+	app.IdempotencySettings.Store = NewMemoryIdempotencyStore()
+
+	app.APIRoutes["payments"] = map[string]APIResource{
+		"POST": {ResourceMethod: "pay", Timeout: 10, Idempotency: &APIResourceIdempotency{}},
+	}
+
+	// the first call outlives the resource timeout until unblocked
+	unblock, returned := make(chan struct{}), make(chan struct{})
+	calls := 0
+
+	app.APIMethods["pay"] = func(r *APIRequest) Result {
+		calls++
+		if calls == 1 {
+			defer close(returned)
+			<-unblock
+		}
+
+		return Result{"OK", "paid"}
+	}
+
+	headers := map[string]string{"Content-Type": "application/json", "Idempotency-Key": "k1"}
+
+	if res := serveTestRequest(t, app, "POST", "/payments", "{}", headers); res.Code != "GEN-0015" {
+		t.Fatalf("code = %v, want GEN-0015", res.Code)
+	}
+
+	// the retry is refused while the late method is running
+	if res := serveTestRequest(t, app, "POST", "/payments", "{}", headers); res.Code != "GEN-0021" {
+		t.Fatalf("retry while running = %v, want GEN-0021", res.Code)
+	}
+
+	close(unblock)
+	<-returned
+
+	// the key is released once the late method returns
+	deadline := time.Now().Add(time.Second)
+
+	for {
+		res := serveTestRequest(t, app, "POST", "/payments", "{}", headers)
+		if res.Code == "OK" {
+			break
+		}
+
+		if res.Code != "GEN-0021" || time.Now().After(deadline) {
+			t.Fatalf("retry after the method returned = %v, want OK", res.Code)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if calls != 2 {
+		t.Errorf("method calls = %v, want 2", calls)
+	}
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    APIRequest
+		scoped bool
+		scope  string
+	}{
+		{"identified user", APIRequest{User: identifiedTestUser("u1"), ExtractedToken: "token"}, true, "user:u1"},
+		{"token without identified user", APIRequest{User: struct{}{}, ExtractedToken: "token"}, true, ""},
+		{"public resource", APIRequest{}, true, "public"},
+		{"authenticated resource without identity", APIRequest{Resource: APIResource{Authentication: true}}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, ok := tt.req.idempotencyScope()
+
+			if ok != tt.scoped {
+				t.Fatalf("scoped = %v, want %v", ok, tt.scoped)
+			}
+
+			if tt.scope != "" && scope != tt.scope {
+				t.Errorf("scope = %q, want %q", scope, tt.scope)
+			}
+		})
+	}
+}
+
+func TestIdempotencyFingerprint(t *testing.T) {
+	r := APIRequest{Method: "POST", Path: "payments", Input: []byte("{}")}
+	fingerprint := r.idempotencyFingerprint("user:u1")
+
+	tests := []struct {
+		name  string
+		req   APIRequest
+		scope string
+		same  bool
+	}{
+		{"same request and identity", r, "user:u1", true},
+		{"other identity", r, "user:u2", false},
+		{"other method", APIRequest{Method: "PUT", Path: "payments", Input: []byte("{}")}, "user:u1", false},
+		{"other path", APIRequest{Method: "POST", Path: "orders", Input: []byte("{}")}, "user:u1", false},
+		{"other body", APIRequest{Method: "POST", Path: "payments", Input: []byte(`{"a":1}`)}, "user:u1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := tt.req.idempotencyFingerprint(tt.scope) == fingerprint; same != tt.same {
+				t.Errorf("same fingerprint = %v, want %v", same, tt.same)
+			}
+		})
+	}
+}
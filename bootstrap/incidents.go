@@ -0,0 +1,64 @@
+package bootstrap
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/ncastellani/partida/utilfunc"
+)
+
+// Incident
+// define the data returned to the client in place of panics and internal
+// errors. the details are logged under the incident ID and only returned
+// to the client when the application runs in debug mode.
+type Incident struct {
+	ID    string `json:"incident_id" xml:"incident_id"`
+	Error string `json:"error,omitempty" xml:"error,omitempty"` // failure details (debug mode only)
+	Stack string `json:"stack,omitempty" xml:"stack,omitempty"` // panic stack trace (debug mode only)
+}
+
+// result codes whose data only concerns the server internals
+var internalCodes = []string{"SE", "GEN-0001", "GEN-0014"}
+
+// recoverResult
+// generate the result for a recovered panic, logging its stack trace.
+// must be called by the deferred function that recovered the panic.
+func (r *APIRequest) recoverResult(rcv interface{}) Result {
+	stack := string(debug.Stack())
+
+	incident := Incident{ID: NewRequestID()}
+
+	r.Logger.Error("request got in panic", "incident_id", incident.ID, "err", rcv, "stack", stack)
+
+	if r.debug {
+		incident.Error = fmt.Sprint(rcv)
+		incident.Stack = stack
+	}
+
+	return Result{"SE", incident}
+}
+
+// concealInternalData
+// replace the result data of internal failures (errors and internal codes)
+// with an incident, so its details are only logged at the server side.
+func (r *APIRequest) concealInternalData() {
+	if _, ok := r.Result.Data.(Incident); ok {
+		return
+	}
+
+	_, isError := r.Result.Data.(error)
+
+	if !isError && !utilfunc.StringInSlice(r.Result.Code, internalCodes) {
+		return
+	}
+
+	incident := Incident{ID: NewRequestID()}
+
+	r.Logger.Error("request result holds internal data", "incident_id", incident.ID, "code", r.Result.Code, "data", fmt.Sprint(r.Result.Data))
+
+	if r.debug {
+		incident.Error = fmt.Sprint(r.Result.Data)
+	}
+
+	r.Result.Data = incident
+}
@@ -0,0 +1,85 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIncidentConcealment(t *testing.T) {
+	app := newTestApplication()
+
+	app.Route("failure").GET(func(r *APIRequest) Result {
+		return Result{"SE", errors.New("dial db:5432: password authentication failed")}
+	})
+	app.Route("error").GET(func(r *APIRequest) Result { return Result{"OK", errors.New("password authentication failed")} })
+	app.Route("panic").GET(func(r *APIRequest) Result { panic("password authentication failed") })
+	app.Route("regular").GET(func(r *APIRequest) Result { return Result{"OK", "password reset sent"} })
+
+	app.APIRoutes["missing"] = map[string]APIResource{"GET": {ResourceMethod: "missingFunction"}}
+
+	tests := []struct {
+		name      string
+		target    string
+		debug     bool
+		code      string
+		concealed bool
+		stack     bool
+	}{
+		{"internal error", "/failure", false, "SE", true, false},
+		{"error data of another code", "/error", false, "OK", true, false},
+		{"panic", "/panic", false, "SE", true, false},
+		{"unknown method function", "/missing", false, "GEN-0001", true, false},
+		{"regular data", "/regular", false, "OK", false, false},
+		{"internal error in debug mode", "/failure", true, "SE", true, false},
+		{"panic in debug mode", "/panic", true, "SE", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			app.DebugMode = tt.debug
+			app.APILogsWriter = &logs
+
+			res := serveTestRequest(t, app, "GET", tt.target, "", nil)
+
+			if res.Code != tt.code {
+				t.Fatalf("code = %v (%s), want %v", res.Code, res.Data, tt.code)
+			}
+
+			if !tt.concealed {
+				if strings.Contains(string(res.Data), "incident_id") {
+					t.Errorf("data = %s, want the method data", res.Data)
+				}
+
+				return
+			}
+
+			var incident Incident
+			if err := json.Unmarshal(res.Data, &incident); err != nil || incident.ID == "" {
+				t.Fatalf("data = %s, want an incident", res.Data)
+			}
+
+			// the details are logged under the incident ID
+			if !strings.Contains(logs.String(), incident.ID) {
+				t.Errorf("logs do not hold the incident %v", incident.ID)
+			}
+
+			// and only returned in debug mode
+			if leaked := incident.Error != ""; leaked != tt.debug {
+				t.Errorf("error returned = %v (%s), want %v", leaked, res.Data, tt.debug)
+			}
+
+			if !tt.debug && (strings.Contains(string(res.Data), "password") || strings.Contains(string(res.Data), "missingFunction")) {
+				t.Errorf("data = %s, leaks the internal details", res.Data)
+			}
+
+			if stack := incident.Stack != ""; stack != tt.stack {
+				t.Errorf("stack returned = %v, want %v", stack, tt.stack)
+			}
+		})
+	}
+}
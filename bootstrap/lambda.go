@@ -0,0 +1,276 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ErrUnknownLambdaEvent
+// returned by the Lambda handler when the event type could not be determined.
+var ErrUnknownLambdaEvent = errors.New("unknown lambda event type")
+
+// lambdaEventProbe
+// hold the fields that determine the type of a Lambda event.
+type lambdaEventProbe struct {
+	Version        string `json:"version"`
+	HTTPMethod     string `json:"httpMethod"`
+	Source         string `json:"source"`
+	DetailType     string `json:"detail-type"`
+	RequestContext *struct {
+		ELB          json.RawMessage `json:"elb"`
+		HTTP         json.RawMessage `json:"http"`
+		ConnectionID string          `json:"connectionId"`
+		DomainName   string          `json:"domainName"`
+	} `json:"requestContext"`
+	Records []struct {
+		EventSource    string `json:"eventSource"`
+		EventSourceSNS string `json:"EventSource"` // SNS records capitalize their fields
+	} `json:"Records"`
+}
+
+// detectLambdaEvent
+// determine the type of a Lambda event by the shape of its payload.
+func detectLambdaEvent(payload json.RawMessage) string {
+	var probe lambdaEventProbe
+
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return ""
+	}
+
+	// HTTP and WebSocket events
+	if c := probe.RequestContext; c != nil {
+		switch {
+		case len(c.ELB) > 0:
+			return "alb"
+		case c.ConnectionID != "":
+			return "websocket"
+		case probe.Version == "2.0" && len(c.HTTP) > 0 && strings.Contains(c.DomainName, ".lambda-url."):
+			return "function_url"
+		case probe.Version == "2.0" && len(c.HTTP) > 0:
+			return "apigateway_v2"
+		case probe.HTTPMethod != "":
+			return "apigateway_v1"
+		}
+	}
+
+	// records events
+	if len(probe.Records) > 0 {
+		switch {
+		case probe.Records[0].EventSource == "aws:sqs":
+			return "sqs"
+		case probe.Records[0].EventSourceSNS == "aws:sns":
+			return "sns"
+		case probe.Records[0].EventSource == "aws:s3":
+			return "s3"
+		case probe.Records[0].EventSource == "aws:dynamodb":
+			return "dynamodb"
+		}
+	}
+
+	// EventBridge events (scheduled ones included)
+	if probe.Source != "" && probe.DetailType != "" {
+		return "eventbridge"
+	}
+
+	return ""
+}
+
+// LambdaHandler
+// handle any supported AWS Lambda event, dispatching it by its type. the HTTP
+// events are handled by the API pipeline and the SQS messages by the queue one.
+// the SNS, S3, DynamoDB Streams and EventBridge events are called as queue
+// methods named after their origin (see triggerEventName), with the record as body.
+func (app *Application) LambdaHandler(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	kind := detectLambdaEvent(payload)
+
+	switch kind {
+	case "apigateway_v1":
+		var e events.APIGatewayProxyRequest
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+
+		return app.APILambdaHandler(ctx, e)
+	case "apigateway_v2":
+		var e events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+
+		return app.APILambdaV2Handler(ctx, e)
+	case "function_url":
+		var e events.LambdaFunctionURLRequest
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+
+		return app.APIFunctionURLHandler(ctx, e)
+	case "alb":
+		var e events.ALBTargetGroupRequest
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+
+		return app.APIALBHandler(ctx, e)
+	case "websocket":
+		var e events.APIGatewayWebsocketProxyRequest
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+
+		return app.APIWebSocketLambdaHandler(ctx, e)
+	case "sqs":
+		var e events.SQSEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+
+		return nil, app.QUEUELambdaHandler(ctx, e)
+	case "sns", "s3", "dynamodb", "eventbridge":
+		records, err := triggerRecords(kind, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, app.handleTriggerRecords(ctx, kind, records)
+	}
+
+	return nil, ErrUnknownLambdaEvent
+}
+
+// triggerRecord
+// define a record of a trigger event, called as a queue event.
+type triggerRecord struct {
+	Name   string      // name of the queue method
+	ID     string      // ID of the record at its origin
+	Record interface{} // record sent as the queue event body
+}
+
+// triggerRecords
+// parse the records of a trigger event and determine their queue method names.
+func triggerRecords(kind string, payload json.RawMessage) (records []triggerRecord, err error) {
+	switch kind {
+	case "sns":
+		var e events.SNSEvent
+		if err = json.Unmarshal(payload, &e); err != nil {
+			return
+		}
+
+		for _, v := range e.Records {
+			records = append(records, triggerRecord{triggerEventName(kind, v.SNS.TopicArn), v.SNS.MessageID, v.SNS})
+		}
+	case "s3":
+		var e events.S3Event
+		if err = json.Unmarshal(payload, &e); err != nil {
+			return
+		}
+
+		for _, v := range e.Records {
+			records = append(records, triggerRecord{triggerEventName(kind, v.S3.Bucket.Name), v.S3.Object.Key + "@" + v.S3.Object.Sequencer, v})
+		}
+	case "dynamodb":
+		var e events.DynamoDBEvent
+		if err = json.Unmarshal(payload, &e); err != nil {
+			return
+		}
+
+		for _, v := range e.Records {
+			records = append(records, triggerRecord{triggerEventName(kind, v.EventSourceArn), v.EventID, v})
+		}
+	case "eventbridge":
+		var e events.CloudWatchEvent
+		if err = json.Unmarshal(payload, &e); err != nil {
+			return
+		}
+
+		origin := e.DetailType
+		if e.DetailType == "Scheduled Event" && len(e.Resources) > 0 {
+			kind, origin = "schedule", e.Resources[0]
+		}
+
+		records = append(records, triggerRecord{triggerEventName(kind, origin), e.ID, e})
+	}
+
+	return
+}
+
+// triggerEventName
+// determine the queue method name of a trigger event by its origin:
+//   - "sns:<topic name>" for SNS notifications
+//   - "s3:<bucket name>" for S3 notifications
+//   - "dynamodb:<table name>" for DynamoDB Streams records
+//   - "schedule:<rule name>" for EventBridge scheduled events
+//   - "eventbridge:<detail type>" for other EventBridge events
+func triggerEventName(kind, origin string) string {
+	switch kind {
+	case "sns":
+		origin = origin[strings.LastIndex(origin, ":")+1:]
+	case "schedule":
+		origin = origin[strings.LastIndex(origin, "/")+1:]
+	case "dynamodb":
+		if _, table, ok := strings.Cut(origin, ":table/"); ok {
+			origin, _, _ = strings.Cut(table, "/")
+		}
+	}
+
+	return kind + ":" + origin
+}
+
+// handleTriggerRecords
+// call the queue method of each trigger record, stopping at the first failure
+// for the records to be retried by their origin.
+func (app *Application) handleTriggerRecords(ctx context.Context, kind string, records []triggerRecord) error {
+
+	// setup a new logger
+	l := app.LogSettings.NewLogger(app.QueueLogsWriter)
+
+	defer app.flushTracing(ctx)
+
+	l.Info("determined the amount of trigger records", "trigger", kind, "count", len(records))
+
+	for k, v := range records {
+		e := QueueEvent{Name: v.Name, ID: v.ID, RequestID: v.ID}
+
+		e.Context = contextWithRequestID(ctx, e.RequestID)
+
+		e.Logger = l.With(
+			slog.String("event_id", e.ID),
+			slog.String("request_id", e.RequestID),
+			slog.String("event", fmt.Sprintf("%v/%v", k+1, len(records))),
+			slog.String("name", e.Name),
+		)
+
+		// convert the record into the generic body
+		b, err := json.Marshal(v.Record)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(b, &e.Body); err != nil {
+			e.Logger.Error("failed to parse the trigger record", "err", err)
+			return err
+		}
+
+		e.Logger.Debug("prepared to handle trigger record", "input", string(b))
+
+		// call the handler
+		e.Logger.Info("calling the trigger event handler...")
+
+		err = app.traceQueueEvent(&e, propagation.MapCarrier{}, semconv.MessagingSystemKey.String("aws_"+kind))
+		if err != nil {
+			e.Logger.Error("failed to call the trigger method", "err", err)
+			return err
+		}
+
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectLambdaEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		payload string
+		kind    string
+	}{
+		{"API Gateway REST", "apigw-request.json", "", "apigateway_v1"},
+		{"API Gateway HTTP", "apigw-v2-request-no-authorizer.json", "", "apigateway_v2"},
+		{"function URL", "lambda-urls-request.json", "", "function_url"},
+		{"ALB", "alb-lambda-target-request-headers-only.json", "", "alb"},
+		{"API Gateway WebSocket", "apigw-websocket-request.json", "", "websocket"},
+		{"SQS", "sqs-event.json", "", "sqs"},
+		{"SNS", "sns-event.json", "", "sns"},
+		{"S3", "s3-event.json", "", "s3"},
+		{"DynamoDB Streams", "dynamodb-event.json", "", "dynamodb"},
+		{"Kinesis", "kinesis-event.json", "", ""},
+		{"Cognito", "cognito-event.json", "", ""},
+		{"EventBridge scheduled", "", `{"id": "1", "source": "aws.events", "detail-type": "Scheduled Event", "resources": ["arn:aws:events:us-east-1:123456789012:rule/nightly"]}`, "eventbridge"},
+		{"EventBridge custom", "", `{"id": "1", "source": "app.orders", "detail-type": "Order Placed", "detail": {}}`, "eventbridge"},
+		{"not an object", "", `[1, 2]`, ""},
+		{"empty object", "", `{}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := []byte(tt.payload)
+
+			if tt.fixture != "" {
+				var err error
+
+				payload, err = os.ReadFile(filepath.Join("testdata", "lambda", tt.fixture))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if kind := detectLambdaEvent(payload); kind != tt.kind {
+				t.Errorf("detectLambdaEvent() = %q, want %q", kind, tt.kind)
+			}
+		})
+	}
+}
+
+func TestLambdaHandlerUnknownEvent(t *testing.T) {
+	app := newTestApplication()
+
+	payload, err := os.ReadFile(filepath.Join("testdata", "lambda", "kinesis-event.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := app.LambdaHandler(context.Background(), payload); !errors.Is(err, ErrUnknownLambdaEvent) {
+		t.Errorf("LambdaHandler() error = %v, want %v", err, ErrUnknownLambdaEvent)
+	}
+}
+
+func TestTriggerEventName(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   string
+		origin string
+		event  string
+	}{
+		{"SNS topic ARN", "sns", "arn:aws:sns:us-east-1:123456789012:orders", "sns:orders"},
+		{"S3 bucket", "s3", "uploads", "s3:uploads"},
+		{"DynamoDB stream ARN", "dynamodb", "arn:aws:dynamodb:us-east-1:123456789012:table/Example-Table/stream/2016-12-01T00:00:00.000", "dynamodb:Example-Table"},
+		{"DynamoDB origin without a table", "dynamodb", "Example-Table", "dynamodb:Example-Table"},
+		{"scheduled rule ARN", "schedule", "arn:aws:events:us-east-1:123456789012:rule/nightly", "schedule:nightly"},
+		{"EventBridge detail type", "eventbridge", "Order Placed", "eventbridge:Order Placed"},
+		{"empty SNS origin", "sns", "", "sns:"},
+		{"empty schedule origin", "schedule", "", "schedule:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if event := triggerEventName(tt.kind, tt.origin); event != tt.event {
+				t.Errorf("triggerEventName(%q, %q) = %q, want %q", tt.kind, tt.origin, event, tt.event)
+			}
+		})
+	}
+}
+
+func TestTriggerRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		fixture string
+		payload string
+		events  []string
+	}{
+		{"SNS", "sns", "sns-event.json", "", []string{"sns:EXAMPLE"}},
+		{"S3", "s3", "s3-event.json", "", []string{"s3:sourcebucket"}},
+		{"DynamoDB Streams", "dynamodb", "dynamodb-event.json", "", []string{"dynamodb:Example-Table", "dynamodb:Example-Table"}},
+		{"EventBridge scheduled", "eventbridge", "", `{"id": "1", "detail-type": "Scheduled Event", "resources": ["arn:aws:events:us-east-1:123456789012:rule/nightly"]}`, []string{"schedule:nightly"}},
+		{"EventBridge scheduled without resources", "eventbridge", "", `{"id": "1", "detail-type": "Scheduled Event"}`, []string{"eventbridge:Scheduled Event"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := []byte(tt.payload)
+
+			if tt.fixture != "" {
+				var err error
+
+				payload, err = os.ReadFile(filepath.Join("testdata", "lambda", tt.fixture))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			records, err := triggerRecords(tt.kind, json.RawMessage(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var events []string
+			for _, v := range records {
+				events = append(events, v.Name)
+			}
+
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Errorf("events = %v, want %v", events, tt.events)
+			}
+		})
+	}
+}
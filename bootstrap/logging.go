@@ -0,0 +1,68 @@
+package bootstrap
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+)
+
+// LogSettings
+// define the level, format and debug sampling of the loggers
+// generated by the application for itself, requests and queue events.
+type LogSettings struct {
+	Level         string  // minimum level of the records (debug/info/warn/error) default = info
+	Format        string  // output format of the records (json or text) default = text
+	DebugSampling float64 // fraction of the generated loggers that keep debug records (0 for all of them)
+}
+
+// NewLogger
+// generate a structured logger that writes into the passed writer. the
+// debug sampling is decided once per logger, so a sampled request keeps
+// all of its debug records and an unsampled one logs from info upwards.
+func (s LogSettings) NewLogger(w io.Writer) *slog.Logger {
+	if w == nil {
+		w = os.Stderr
+	}
+
+	// determine the minimum level of the records
+	level := slog.LevelInfo
+
+	if s.Level != "" {
+		if err := level.UnmarshalText([]byte(s.Level)); err != nil {
+			level = slog.LevelInfo
+		}
+	}
+
+	// raise the level of the loggers left out of the debug sampling
+	if level < slog.LevelInfo && s.DebugSampling > 0 && s.DebugSampling < 1 && rand.Float64() >= s.DebugSampling {
+		level = slog.LevelInfo
+	}
+
+	// generate the handler for the requested format
+	opts := &slog.HandlerOptions{Level: level}
+
+	if s.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// userLogAttr
+// return the log attribute for the authorized user. only users that
+// know how to be represented (strings, fmt.Stringer and slog.LogValuer)
+// are logged, avoiding to dump entire user structures into the logs.
+func userLogAttr(user interface{}) (attr slog.Attr, ok bool) {
+	switch v := user.(type) {
+	case string:
+		return slog.String("user", v), true
+	case slog.LogValuer:
+		return slog.Any("user", v), true
+	case fmt.Stringer:
+		return slog.String("user", v.String()), true
+	}
+
+	return
+}
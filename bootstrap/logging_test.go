@@ -0,0 +1,113 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// stringerUser
+// define an user represented by fmt.Stringer.
+type stringerUser struct{ name string }
+
+func (u stringerUser) String() string { return "user " + u.name }
+
+// valuerUser
+// define an user represented by slog.LogValuer.
+type valuerUser struct {
+	ID       string
+	Password string
+}
+
+func (u valuerUser) LogValue() slog.Value { return slog.StringValue("id " + u.ID) }
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings LogSettings
+		debug    bool
+		info     bool
+	}{
+		{"default level", LogSettings{}, false, true},
+		{"debug level", LogSettings{Level: "debug"}, true, true},
+		{"warn level", LogSettings{Level: "warn"}, false, false},
+		{"invalid level", LogSettings{Level: "verbose"}, false, true},
+		{"every logger sampled", LogSettings{Level: "debug", DebugSampling: 1}, true, true},
+		{"no logger sampled", LogSettings{Level: "debug", DebugSampling: 1e-12}, false, true},
+		{"sampling above the debug level", LogSettings{Level: "warn", DebugSampling: 1e-12}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			l := tt.settings.NewLogger(&b)
+
+			l.Debug("debug record")
+			if logged := b.Len() > 0; logged != tt.debug {
+				t.Errorf("debug logged = %v, want %v", logged, tt.debug)
+			}
+
+			b.Reset()
+
+			l.Info("info record")
+			if logged := b.Len() > 0; logged != tt.info {
+				t.Errorf("info logged = %v, want %v", logged, tt.info)
+			}
+		})
+	}
+}
+
+func TestNewLoggerFormat(t *testing.T) {
+	var b bytes.Buffer
+
+	LogSettings{Format: "json"}.NewLogger(&b).Info("hello", "request_id", "req-1")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &record); err != nil {
+		t.Fatalf("json record %q: %v", b.String(), err)
+	}
+
+	if record["msg"] != "hello" || record["request_id"] != "req-1" || record["level"] != "INFO" {
+		t.Errorf("record = %v", record)
+	}
+
+	b.Reset()
+
+	LogSettings{}.NewLogger(&b).Info("hello", "request_id", "req-1")
+
+	if line := b.String(); !strings.Contains(line, "level=INFO") || !strings.Contains(line, "request_id=req-1") {
+		t.Errorf("text record = %q", line)
+	}
+}
+
+func TestUserLogAttr(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   interface{}
+		logged bool
+		value  string
+	}{
+		{"string", "ana", true, "ana"},
+		{"fmt.Stringer", stringerUser{"ana"}, true, "user ana"},
+		{"slog.LogValuer", valuerUser{"u1", "secret"}, true, "id u1"},
+		{"structure", struct{ Password string }{"secret"}, false, ""},
+		{"no user", nil, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attr, ok := userLogAttr(tt.user)
+
+			if ok != tt.logged {
+				t.Fatalf("logged = %v, want %v", ok, tt.logged)
+			}
+
+			if ok && attr.Value.Resolve().String() != tt.value {
+				t.Errorf("value = %q, want %q", attr.Value.Resolve().String(), tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsSettings
+// define how the application metrics are exposed: as a built-in route at the
+// net/http adapter and as CloudWatch EMF log records at the Lambda handlers.
+// the route is answered before the API pipeline, without authentication, so
+// public deployments should restrict it by network or serve it at its own address.
+type MetricsSettings struct {
+	Route     string             // path of the built-in metrics route (ex: "metrics"), empty to disable it
+	Network   APIResourceNetwork // network policy of the metrics route, as the resources one
+	Address   string             // address of a separate listener for the metrics route (ex: ":9090"), started by ListenAndServe instead of answering it at the API one
+	Namespace string             // CloudWatch namespace of the EMF records, empty to disable them
+	EMFWriter io.Writer          // writer of the EMF records, default = os.Stdout
+}
+
+// applicationMetrics
+// hold the registry and the collectors of the application metrics.
+type applicationMetrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestsTime    *prometheus.HistogramVec
+	stagesTime      *prometheus.HistogramVec
+	queueEvents     *prometheus.CounterVec
+	queueEventsTime *prometheus.HistogramVec
+	configReloads   *prometheus.CounterVec
+	configReloaded  prometheus.Gauge
+}
+
+// stageTiming
+// define the duration of a pipeline stage at a request.
+type stageTiming struct {
+	stage    string
+	duration time.Duration
+}
+
+// newApplicationMetrics
+// generate a registry with the Go runtime collectors and the API and queue metrics.
+func newApplicationMetrics() *applicationMetrics {
+	m := &applicationMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "partida_api_requests_total",
+			Help: "Amount of API requests answered, by route, HTTP method and result code.",
+		}, []string{"route", "method", "code"}),
+		requestsTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "partida_api_request_duration_seconds",
+			Help:    "Duration of the API requests, by route, HTTP method and result code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+		stagesTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "partida_api_stage_duration_seconds",
+			Help:    "Duration of the API pipeline stages, by stage and route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"stage", "route"}),
+		queueEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "partida_queue_events_total",
+			Help: "Amount of queue events handled, by event name and outcome.",
+		}, []string{"name", "outcome"}),
+		queueEventsTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "partida_queue_event_duration_seconds",
+			Help:    "Duration of the queue events methods, by event name and outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"name", "outcome"}),
+		configReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "partida_config_reloads_total",
+			Help: "Amount of codes and routes files reloads, by outcome.",
+		}, []string{"outcome"}),
+		configReloaded: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "partida_config_last_reload_timestamp_seconds",
+			Help: "Unix time of the last successful codes and routes files reload.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestsTime,
+		m.stagesTime,
+		m.queueEvents,
+		m.queueEventsTime,
+		m.configReloads,
+		m.configReloaded,
+	)
+
+	return m
+}
+
+// MetricsRegistry
+// return the registry with the application metrics, allowing it to be served
+// by other routes or to have more collectors registered on it.
+func (app *Application) MetricsRegistry() *prometheus.Registry {
+	if app.metrics == nil {
+		return nil
+	}
+
+	return app.metrics.registry
+}
+
+// MetricsHandler
+// return an HTTP handler that serves the application metrics on the Prometheus format.
+func (app *Application) MetricsHandler() http.Handler {
+	if app.metrics == nil {
+		return http.NotFoundHandler()
+	}
+
+	return promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{})
+}
+
+// serveMetricsRoute
+// answer the request with the metrics if it is for the built-in metrics route
+// and the network policy accepts its IP address.
+func (app *Application) serveMetricsRoute(w http.ResponseWriter, e *http.Request, ip string) {
+	if !app.MetricsSettings.Network.allows(ip) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	app.MetricsHandler().ServeHTTP(w, e)
+}
+
+// requestLabels
+// determine the route and method labels of a request. requests that did not match a
+// resource are labeled as "unmatched", keeping unknown paths out of the label values.
+func requestLabels(r *APIRequest) (route, method string) {
+	if r.Resource.ResourceMethod == "" {
+		return "unmatched", r.Method
+	}
+
+	return r.Path, r.Method
+}
+
+// recordRequestMetrics
+// observe the request count and durations at the metrics registry.
+func (app *Application) recordRequestMetrics(r *APIRequest) {
+	if app.metrics == nil {
+		return
+	}
+
+	route, method := requestLabels(r)
+
+	app.metrics.requests.WithLabelValues(route, method, r.Result.Code).Inc()
+	app.metrics.requestsTime.WithLabelValues(route, method, r.Result.Code).Observe(r.duration.Seconds())
+
+	for _, v := range r.stages {
+		app.metrics.stagesTime.WithLabelValues(v.stage, route).Observe(v.duration.Seconds())
+	}
+}
+
+// recordQueueEventMetrics
+// observe the queue event outcome and duration at the metrics registry
+// and, as queue events are handled at Lambda, emit them as an EMF record.
+func (app *Application) recordQueueEventMetrics(e *QueueEvent, err error, duration time.Duration) {
+	name := e.Name
+	if _, ok := app.QueueMethods[name]; !ok {
+		name = "unknown"
+	}
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+
+	if app.metrics != nil {
+		app.metrics.queueEvents.WithLabelValues(name, outcome).Inc()
+		app.metrics.queueEventsTime.WithLabelValues(name, outcome).Observe(duration.Seconds())
+	}
+
+	app.emitEMF(
+		map[string]string{"Event": name, "Outcome": outcome},
+		[]emfMetric{{"Events", "Count", 1}, {"Duration", "Milliseconds", durationMilliseconds(duration)}},
+	)
+}
+
+// emitRequestEMF
+// emit the request metrics as an EMF record for the Lambda handlers,
+// except for the built-in health routes.
+func (app *Application) emitRequestEMF(r *APIRequest) {
+	if r.probe {
+		return
+	}
+
+	route, method := requestLabels(r)
+
+	metrics := []emfMetric{{"Requests", "Count", 1}, {"Latency", "Milliseconds", durationMilliseconds(r.duration)}}
+	for _, v := range r.stages {
+		metrics = append(metrics, emfMetric{"Stage." + v.stage, "Milliseconds", durationMilliseconds(v.duration)})
+	}
+
+	app.emitEMF(map[string]string{"Route": route, "Method": method, "Code": r.Result.Code}, metrics)
+}
+
+// emfMetric
+// define a metric value of an EMF record.
+type emfMetric struct {
+	Name  string
+	Unit  string
+	Value float64
+}
+
+// emitEMF
+// write a CloudWatch Embedded Metric Format record with the passed
+// dimensions and metrics as a single JSON line at the EMF writer.
+func (app *Application) emitEMF(dimensions map[string]string, metrics []emfMetric) {
+	if app.MetricsSettings.Namespace == "" {
+		return
+	}
+
+	// assemble the metrics directive and the record values
+	record := make(map[string]interface{})
+
+	dimensionsKeys := make([]string, 0, len(dimensions))
+	for k, v := range dimensions {
+		dimensionsKeys = append(dimensionsKeys, k)
+		record[k] = v
+	}
+
+	definitions := make([]map[string]string, 0, len(metrics))
+	for _, v := range metrics {
+		definitions = append(definitions, map[string]string{"Name": v.Name, "Unit": v.Unit})
+		record[v.Name] = v.Value
+	}
+
+	record["_aws"] = map[string]interface{}{
+		"Timestamp": time.Now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]interface{}{{
+			"Namespace":  app.MetricsSettings.Namespace,
+			"Dimensions": [][]string{dimensionsKeys},
+			"Metrics":    definitions,
+		}},
+	}
+
+	// write the record as a single line
+	line, err := json.Marshal(record)
+	if err != nil {
+		app.Logger.Warn("failed to marshal the EMF record", "err", err)
+		return
+	}
+
+	w := app.MetricsSettings.EMFWriter
+	if w == nil {
+		w = os.Stdout
+	}
+
+	w.Write(append(line, '\n'))
+}
+
+// durationMilliseconds
+// convert a duration into fractional milliseconds.
+func durationMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
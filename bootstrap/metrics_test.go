@@ -0,0 +1,158 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsRoute(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings MetricsSettings
+		status   int
+	}{
+		{"public route", MetricsSettings{Route: "metrics"}, http.StatusOK},
+		{"denied network", MetricsSettings{Route: "metrics", Network: APIResourceNetwork{Default: "deny", Exceptions: []string{"10.0.0.0/8"}}}, http.StatusForbidden},
+		{"allowed network", MetricsSettings{Route: "metrics", Network: APIResourceNetwork{Default: "deny", Exceptions: []string{"192.0.2.0/24"}}}, http.StatusOK},
+		{"served at its own address", MetricsSettings{Route: "metrics", Address: ":9090"}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication()
+			app.metrics = newApplicationMetrics()
+			app.MetricsSettings = tt.settings
+
+			// the test requests come from 192.0.2.1
+			w := httptest.NewRecorder()
+			app.APIHTTPHandler(w, httptest.NewRequest("GET", "/metrics", nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %v, want %v", w.Code, tt.status)
+			}
+
+			if tt.status == http.StatusOK && !strings.Contains(w.Body.String(), "go_goroutines") {
+				t.Errorf("body = %q, want the Prometheus metrics", w.Body)
+			}
+		})
+	}
+}
+
+func TestMetricsRouteCollision(t *testing.T) {
+	app := newTestApplication()
+	app.MetricsSettings = MetricsSettings{Route: "metrics"}
+
+	if err := app.checkBuiltinRoutes(map[string]map[string]APIResource{"metrics": {}}); err == nil {
+		t.Error("checkBuiltinRoutes() = nil, want the collision error")
+	}
+
+	// the route served at its own address does not collide
+	app.MetricsSettings.Address = ":9090"
+
+	if err := app.checkBuiltinRoutes(map[string]map[string]APIResource{"metrics": {}}); err != nil {
+		t.Errorf("checkBuiltinRoutes() = %v, want nil", err)
+	}
+}
+
+func TestRequestMetricsLabels(t *testing.T) {
+	app := newTestApplication()
+	app.metrics = newApplicationMetrics()
+
+	app.Route("users").GET(func(r *APIRequest) Result { return Result{"OK", nil} })
+
+	serveTestRequest(t, app, "GET", "/users", "", nil)
+	serveTestRequest(t, app, "GET", "/users", "", nil)
+	serveTestRequest(t, app, "GET", "/unknown/path", "", nil)
+
+	// scrape the metrics on the Prometheus text format
+	w := httptest.NewRecorder()
+	app.MetricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	tests := []struct {
+		name    string
+		series  string
+		scraped bool
+	}{
+		{"matched route", `partida_api_requests_total{code="OK",method="GET",route="users"} 2`, true},
+		{"unknown paths out of the labels", `partida_api_requests_total{code="GEN-0004",method="GET",route="unmatched"} 1`, true},
+		{"unknown path as label", `route="unknown/path"`, false},
+		{"request duration", `partida_api_request_duration_seconds_count{code="OK",method="GET",route="users"} 2`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if scraped := strings.Contains(w.Body.String(), tt.series); scraped != tt.scraped {
+				t.Errorf("%v scraped = %v, want %v", tt.series, scraped, tt.scraped)
+			}
+		})
+	}
+}
+
+func TestEmitRequestEMF(t *testing.T) {
+	var b bytes.Buffer
+
+	app := newTestApplication()
+	app.MetricsSettings = MetricsSettings{Namespace: "partida", EMFWriter: &b}
+
+	r := &APIRequest{
+		Method:   "GET",
+		Path:     "users",
+		Resource: APIResource{ResourceMethod: "GET users"},
+		Result:   Result{Code: "OK"},
+		duration: 2 * time.Millisecond,
+		stages:   []stageTiming{{"method", time.Millisecond}},
+	}
+
+	app.emitRequestEMF(r)
+
+	var record struct {
+		AWS struct {
+			Timestamp         int64
+			CloudWatchMetrics []struct {
+				Namespace  string
+				Dimensions [][]string
+				Metrics    []map[string]string
+			}
+		} `json:"_aws"`
+		Route    string
+		Method   string
+		Code     string
+		Requests float64
+		Latency  float64
+		Stage    float64 `json:"Stage.method"`
+	}
+
+	if err := json.Unmarshal(b.Bytes(), &record); err != nil {
+		t.Fatalf("EMF record %q: %v", b.String(), err)
+	}
+
+	if record.Route != "users" || record.Method != "GET" || record.Code != "OK" || record.Requests != 1 || record.Latency != 2 || record.Stage != 1 {
+		t.Errorf("record values = %+v", record)
+	}
+
+	if len(record.AWS.CloudWatchMetrics) != 1 || record.AWS.Timestamp == 0 {
+		t.Fatalf("metrics directive = %+v", record.AWS)
+	}
+
+	directive := record.AWS.CloudWatchMetrics[0]
+	if directive.Namespace != "partida" || len(directive.Dimensions) != 1 || len(directive.Dimensions[0]) != 3 || len(directive.Metrics) != 3 {
+		t.Errorf("metrics directive = %+v", directive)
+	}
+
+	// the health probes and the disabled namespace do not emit records
+	b.Reset()
+
+	app.emitRequestEMF(&APIRequest{probe: true})
+
+	app.MetricsSettings.Namespace = ""
+	app.emitRequestEMF(r)
+
+	if b.Len() > 0 {
+		t.Errorf("record = %q, want none", b.String())
+	}
+}
@@ -0,0 +1,291 @@
+package bootstrap
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/guregu/dynamo"
+	"github.com/ncastellani/partida/utilfunc"
+)
+
+// max length of the "cursor" query parameter values
+const maxCursorLength = 2048
+
+// PaginationSettings
+// define the default page sizes and the key that signs the pagination cursors.
+type PaginationSettings struct {
+	CursorSecret []byte // HMAC key of the cursors, shared by the application instances
+	DefaultLimit int    // page size of the requests without "limit", default = 20
+	MaxLimit     int    // max page size of the requests, default = 100
+}
+
+// APIResourcePagination
+// define the page sizes of a list resource, overriding the application ones.
+type APIResourcePagination struct {
+	DefaultLimit int `json:"default_limit"`
+	MaxLimit     int `json:"max_limit"`
+}
+
+// Page
+// define the page requested by the "limit" and "cursor" query parameters.
+type Page struct {
+	Limit  int    // amount of items of the page
+	Cursor string // verified cursor value (as set by SetNextCursor), empty for the first page
+}
+
+// checkCursorSecret
+// warn when the routes have paginated resources but the cursor secret was
+// generated for this instance, as their cursors are rejected by the other
+// instances and after a restart.
+func (app *Application) checkCursorSecret(routes map[string]map[string]APIResource) {
+	if app.generatedCursorSecret == nil || !bytes.Equal(app.PaginationSettings.CursorSecret, app.generatedCursorSecret) {
+		return
+	}
+
+	for route, resources := range routes {
+		for method, v := range resources {
+			if v.Pagination != nil {
+				app.Logger.Warn("paginated resource without a configured cursor secret (APP_CURSOR_SECRET), its cursors are only valid at this instance", "route", route, "method", method)
+				return
+			}
+		}
+	}
+}
+
+// parsePagination
+// determine the page of the request from the "limit" and "cursor" query
+// parameters, verifying the signature of the cursor.
+func (r *APIRequest) parsePagination(settings *PaginationSettings) {
+	if r.Result.Code != "OK" || r.Resource.Pagination == nil {
+		return
+	}
+
+	r.cursorSecret = settings.CursorSecret
+
+	defaultLimit, maxLimit := settings.DefaultLimit, settings.MaxLimit
+	if r.Resource.Pagination.DefaultLimit > 0 {
+		defaultLimit = r.Resource.Pagination.DefaultLimit
+	}
+
+	if r.Resource.Pagination.MaxLimit > 0 {
+		maxLimit = r.Resource.Pagination.MaxLimit
+	}
+
+	if defaultLimit == 0 {
+		defaultLimit = 20
+	}
+
+	if maxLimit == 0 {
+		maxLimit = 100
+	}
+
+	// parse the page size
+	r.page = Page{Limit: defaultLimit}
+
+	if v, ok := r.Query["limit"]; ok {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			r.Logger.Info("the requested page size is invalid", "limit", v, "max_limit", maxLimit)
+
+			invalid := []APIResourceParameter{{Name: "limit", Kind: "number", QueryParameter: true}}
+
+			r.updateResult("GEN-0013", struct {
+				Missing *[]APIResourceParameter `json:"missing"`
+				Invalid *[]APIResourceParameter `json:"invalid"`
+			}{
+				Missing: &[]APIResourceParameter{},
+				Invalid: &invalid,
+			})
+
+			return
+		}
+
+		r.page.Limit = limit
+	}
+
+	// verify and decode the cursor
+	if v := r.Query["cursor"]; v != "" {
+		cursor, ok := "", len(v) <= maxCursorLength
+		if ok {
+			cursor, ok = verifyCursor(r.cursorSecret, r.cursorRoute(), v)
+		}
+
+		if !ok {
+			r.Logger.Info("the pagination cursor is invalid")
+
+			r.updateResult("GEN-0026", utilfunc.Empty)
+			return
+		}
+
+		r.page.Cursor = cursor
+	}
+
+	r.Logger.Debug("determined the requested page", "limit", r.page.Limit, "first", r.page.Cursor == "")
+
+}
+
+// Page
+// return the page requested to a paginated resource.
+func (r *APIRequest) Page() Page {
+	return r.page
+}
+
+// SetNextCursor
+// set the cursor of the next page of a paginated resource, empty if this is the
+// last page. it is signed and returned at the "next_cursor" meta and "Link" header.
+func (r *APIRequest) SetNextCursor(cursor string) {
+	r.nextCursor = cursor
+}
+
+// PagingKey
+// return the DynamoDB key to start the requested page from, nil for the first page.
+func (r *APIRequest) PagingKey() (dynamo.PagingKey, error) {
+	if r.page.Cursor == "" {
+		return nil, nil
+	}
+
+	var key dynamo.PagingKey
+
+	if err := json.Unmarshal([]byte(r.page.Cursor), &key); err != nil {
+		return nil, fmt.Errorf("cursor does not hold a DynamoDB paging key: %w", err)
+	}
+
+	return key, nil
+}
+
+// SetNextPagingKey
+// set the DynamoDB "LastEvaluatedKey" as the cursor of the next page.
+func (r *APIRequest) SetNextPagingKey(key dynamo.PagingKey) error {
+	if len(key) == 0 {
+		r.SetNextCursor("")
+		return nil
+	}
+
+	b, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	// drop the empty attribute value types (keys only hold scalars) to shorten the cursor
+	var attributes map[string]map[string]json.RawMessage
+
+	if err := json.Unmarshal(b, &attributes); err != nil {
+		return err
+	}
+
+	for _, types := range attributes {
+		for k, v := range types {
+			if string(v) == "null" {
+				delete(types, k)
+			}
+		}
+	}
+
+	if b, err = json.Marshal(attributes); err != nil {
+		return err
+	}
+
+	r.SetNextCursor(string(b))
+
+	return nil
+}
+
+// setPaginationHeaders
+// set the "Link" header of the next and first pages of a paginated resource.
+func (r *APIRequest) setPaginationHeaders(headers map[string]string) {
+	if r.Resource.Pagination == nil || r.Result.Code != "OK" || r.nextCursor == "" {
+		return
+	}
+
+	link := func(cursor, rel string) string {
+		query := make(map[string]string)
+		for k, v := range r.Query {
+			query[k] = v
+		}
+
+		delete(query, "cursor")
+		if cursor != "" {
+			query["cursor"] = cursor
+		}
+
+		target := "/" + r.Path
+		if v := encodeQuery(query); v != "" {
+			target += "?" + v
+		}
+
+		return fmt.Sprintf("<%v>; rel=%q", target, rel)
+	}
+
+	headers["Link"] = strings.Join([]string{
+		link(signCursor(r.cursorSecret, r.cursorRoute(), r.nextCursor), "next"),
+		link("", "first"),
+	}, ", ")
+}
+
+// paginationMeta
+// return the "next_cursor" and "has_more" meta fields of a paginated resource.
+func (r *APIRequest) paginationMeta() (nextCursor string, hasMore *bool) {
+	if r.Resource.Pagination == nil || r.Result.Code != "OK" {
+		return
+	}
+
+	more := r.nextCursor != ""
+	if more {
+		nextCursor = signCursor(r.cursorSecret, r.cursorRoute(), r.nextCursor)
+	}
+
+	return nextCursor, &more
+}
+
+// cursorRoute
+// return the method and path of the request, which the cursors are bound to.
+func (r *APIRequest) cursorRoute() string {
+	return r.Method + " /" + r.Path
+}
+
+// cursorMAC
+// return the HMAC signature of a cursor value issued by a route.
+func cursorMAC(secret []byte, route, value string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%v\n%v", route, value)
+
+	return mac.Sum(nil)
+}
+
+// signCursor
+// encode the cursor value with its HMAC signature as an opaque token.
+// the signature covers the route, so the token is only valid at it.
+func signCursor(secret []byte, route, value string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(value)) + "." + base64.RawURLEncoding.EncodeToString(cursorMAC(secret, route, value))
+}
+
+// verifyCursor
+// decode an opaque cursor token, checking its HMAC signature for the route.
+func verifyCursor(secret []byte, route, token string) (value string, ok bool) {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return "", false
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", false
+	}
+
+	sum, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return "", false
+	}
+
+	if !hmac.Equal(sum, cursorMAC(secret, route, string(b))) {
+		return "", false
+	}
+
+	return string(b), true
+}
@@ -0,0 +1,120 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCursorSignature(t *testing.T) {
+	secret := []byte("secret")
+	token := signCursor(secret, "GET /items", "item-20")
+
+	payload, signature, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name   string
+		secret []byte
+		route  string
+		token  string
+		value  string
+		ok     bool
+	}{
+		{"signed cursor", secret, "GET /items", token, "item-20", true},
+		{"other secret", []byte("other"), "GET /items", token, "", false},
+		{"other path", secret, "GET /users", token, "", false},
+		{"other method", secret, "POST /items", token, "", false},
+		{"tampered payload", secret, "GET /items", signCursor([]byte("other"), "GET /items", "item-40")[:len(payload)] + "." + signature, "", false},
+		{"missing signature", secret, "GET /items", payload, "", false},
+		{"invalid encoding", secret, "GET /items", "!!." + signature, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, ok := verifyCursor(tt.secret, tt.route, tt.token)
+
+			if ok != tt.ok || value != tt.value {
+				t.Errorf("verifyCursor() = %q %v, want %q %v", value, ok, tt.value, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPagination(t *testing.T) {
+	app := newTestApplication()
+	app.PaginationSettings = PaginationSettings{CursorSecret: []byte("secret"), DefaultLimit: 2, MaxLimit: 3}
+
+	items := []string{"a", "b", "c", "d", "e"}
+
+	// list the items after the cursor (the index of the next item)
+	app.Route("items").GET(func(r *APIRequest) Result {
+		start, _ := strconv.Atoi(r.Page().Cursor)
+
+		end := start + r.Page().Limit
+		if end < len(items) {
+			r.SetNextCursor(strconv.Itoa(end))
+		} else {
+			end = len(items)
+		}
+
+		return Result{"OK", items[start:end]}
+	}).Pagination(APIResourcePagination{})
+
+	tests := []struct {
+		name    string
+		query   string
+		code    string
+		items   []string
+		hasMore bool
+	}{
+		{"default page size", "", "OK", []string{"a", "b"}, true},
+		{"requested page size", "limit=3", "OK", []string{"a", "b", "c"}, true},
+		{"page size over the max", "limit=4", "GEN-0013", nil, false},
+		{"invalid page size", "limit=none", "GEN-0013", nil, false},
+		{"next page", "limit=3&cursor=" + signCursor([]byte("secret"), "GET /items", "3"), "OK", []string{"d", "e"}, false},
+		{"cursor not signed by the application", "cursor=" + signCursor([]byte("other"), "GET /items", "3"), "GEN-0026", nil, false},
+		{"cursor issued by another route", "cursor=" + signCursor([]byte("secret"), "GET /users", "3"), "GEN-0026", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serveTestRequest(t, app, "GET", "/items?"+tt.query, "", nil)
+
+			if res.Code != tt.code {
+				t.Fatalf("code = %v (%s), want %v", res.Code, res.Data, tt.code)
+			}
+
+			if tt.code != "OK" {
+				return
+			}
+
+			var got []string
+			if err := json.Unmarshal(res.Data, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Join(got, ",") != strings.Join(tt.items, ",") {
+				t.Errorf("items = %v, want %v", got, tt.items)
+			}
+
+			if res.Meta.HasMore == nil || *res.Meta.HasMore != tt.hasMore {
+				t.Fatalf("has_more = %v, want %v", res.Meta.HasMore, tt.hasMore)
+			}
+
+			if !tt.hasMore {
+				return
+			}
+
+			// the next cursor is signed and linked with the same page size
+			if _, ok := verifyCursor([]byte("secret"), "GET /items", res.Meta.NextCursor); !ok {
+				t.Errorf("next_cursor %q is not signed by the application", res.Meta.NextCursor)
+			}
+
+			if link := res.Headers["Link"]; !strings.Contains(link, "cursor="+url.QueryEscape(res.Meta.NextCursor)) {
+				t.Errorf("Link = %q does not hold the next cursor", link)
+			}
+		})
+	}
+}
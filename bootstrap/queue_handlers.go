@@ -4,11 +4,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 	"github.com/bytedance/sonic"
-	"github.com/ncastellani/partida"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // QUEUELambdaHandler
@@ -16,19 +26,31 @@ import (
 func (app *Application) QUEUELambdaHandler(ctx context.Context, msgs events.SQSEvent) (err error) {
 
 	// setup a new logger
-	l := log.New(app.QueueLogsWriter, "", log.Lmsgprefix)
+	l := app.LogSettings.NewLogger(app.QueueLogsWriter)
+
+	defer app.flushTracing(ctx)
 
 	// handle the messages
-	l.Printf("determined the amount of messages [count: %v]", len(msgs.Records))
+	l.Info("determined the amount of messages", "count", len(msgs.Records))
 
 	for k, msg := range msgs.Records {
-		e := QueueEvent{ID: msg.MessageId}
+		e := QueueEvent{ID: msg.MessageId, RequestID: msg.MessageId}
+
+		// determine the correlation ID forwarded by the enqueuer
+		if v := sqsMessageCarrier(msg.MessageAttributes).Get(requestIDAttribute); v != "" {
+			e.RequestID = v
+		}
+
+		e.Context = contextWithRequestID(ctx, e.RequestID)
 
 		// generate a logger for this event
-		e.Logger = log.New(l.Writer(), fmt.Sprintf("event(%v/%v) [queue_%v] > ", (k+1), len(msgs.Records), partida.RandomString(4)), log.Lmsgprefix)
+		e.Logger = l.With(
+			slog.String("event_id", e.ID),
+			slog.String("request_id", e.RequestID),
+			slog.String("event", fmt.Sprintf("%v/%v", k+1, len(msgs.Records))),
+		)
 
-		e.Logger.Println("======================================================")
-		e.Logger.Println("generated a logger for this message")
+		e.Logger.Debug("generated a logger for this message")
 
 		// print out the record inputted value
 		msgJSON, err := sonic.Marshal(&msg)
@@ -36,37 +58,39 @@ func (app *Application) QUEUELambdaHandler(ctx context.Context, msgs events.SQSE
 			return err
 		}
 
-		e.Logger.Printf("prepared to handle message [input: %v]", string(msgJSON))
+		e.Logger.Debug("prepared to handle message", "input", string(msgJSON))
 
 		// determine the event
 		if val, ok := msg.MessageAttributes["METHOD"]; !ok {
-			e.Logger.Println("no METHOD at the message attributes")
+			e.Logger.Error("no METHOD at the message attributes")
 			return errors.New("no METHOD attribute was found")
 		} else {
 			if val.DataType != "String" {
-				e.Logger.Printf("METHOD attribute not at the desired type [type: %v]", val.DataType)
+				e.Logger.Error("METHOD attribute not at the desired type", "type", val.DataType)
 				return errors.New("the passed 'METHOD' data type is not valid (must be string)")
 			}
 		}
 
 		e.Name = *msg.MessageAttributes["METHOD"].StringValue
-		e.Logger.Printf("determined the event name [name: %v]", e.Name)
+		e.Logger = e.Logger.With(slog.String("name", e.Name))
+
+		e.Logger.Debug("determined the event name")
 
 		// parse the body JSON
 		err = sonic.UnmarshalString(msg.Body, &e.Body)
 		if err != nil {
-			e.Logger.Printf("failed to parse the message JSON body [err: %v]", err)
+			e.Logger.Error("failed to parse the message JSON body", "err", err)
 			return err
 		}
 
-		e.Logger.Printf("parsed the message body [body: %v]", e.Body)
+		e.Logger.Debug("parsed the message body", "body", e.Body)
 
 		// call the handler
-		e.Logger.Println("calling the queue event handler...")
+		e.Logger.Info("calling the queue event handler...")
 
-		err = app.callQueueEvent(&e)
+		err = app.traceQueueEvent(&e, sqsMessageCarrier(msg.MessageAttributes), semconv.MessagingSystemAWSSqs)
 		if err != nil {
-			e.Logger.Printf("failed to call the queue method [err: %v]", err)
+			e.Logger.Error("failed to call the queue method", "err", err)
 			return err
 		}
 
@@ -75,30 +99,137 @@ func (app *Application) QUEUELambdaHandler(ctx context.Context, msgs events.SQSE
 	return
 }
 
+// ConsumeQueue
+// receive and handle the events of the SQS queue (APP_QUEUE) in this process,
+// as an alternative to the Lambda trigger, until the context is done.
+// the batch being handled when the context is done is still finished.
+func (app *Application) ConsumeQueue(ctx context.Context) {
+	app.consumeQueue(ctx, QUEUE, os.Getenv("APP_QUEUE"))
+}
+
+// consumeQueue
+// long poll the queue, handle each received message and delete it once handled.
+// the messages after a failed one are left to be delivered again (keeping the order of FIFO queues).
+func (app *Application) consumeQueue(ctx context.Context, client sqsiface.SQSAPI, url string) {
+	l := app.LogSettings.NewLogger(app.QueueLogsWriter)
+
+	l.Info("consuming the queue events...", "queue", url)
+
+	for ctx.Err() == nil {
+		out, err := client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(url),
+			MaxNumberOfMessages:   aws.Int64(10),
+			WaitTimeSeconds:       aws.Int64(20),
+			MessageAttributeNames: []*string{aws.String("All")},
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+
+			l.Error("failed to receive the queue messages", "err", err)
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+
+			continue
+		}
+
+		// the handling is not cancelled by the shutdown
+		for _, msg := range out.Messages {
+			err = app.QUEUELambdaHandler(context.WithoutCancel(ctx), events.SQSEvent{Records: []events.SQSMessage{sqsEventMessage(msg)}})
+			if err != nil {
+				break
+			}
+
+			_, err = client.DeleteMessageWithContext(context.WithoutCancel(ctx), &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(url),
+				ReceiptHandle: msg.ReceiptHandle,
+			})
+			if err != nil {
+				l.Error("failed to delete the handled queue message", "err", err, "event_id", aws.StringValue(msg.MessageId))
+			}
+		}
+	}
+
+	l.Info("stopped consuming the queue events")
+}
+
+// sqsEventMessage
+// convert a received SQS message to the one delivered by the Lambda trigger
+func sqsEventMessage(msg *sqs.Message) events.SQSMessage {
+	m := events.SQSMessage{
+		MessageId:         aws.StringValue(msg.MessageId),
+		ReceiptHandle:     aws.StringValue(msg.ReceiptHandle),
+		Body:              aws.StringValue(msg.Body),
+		MessageAttributes: make(map[string]events.SQSMessageAttribute, len(msg.MessageAttributes)),
+	}
+
+	for k, v := range msg.MessageAttributes {
+		m.MessageAttributes[k] = events.SQSMessageAttribute{DataType: aws.StringValue(v.DataType), StringValue: v.StringValue}
+	}
+
+	return m
+}
+
+// traceQueueEvent
+// call the queue event within a span, child of the trace propagated
+// by the carrier (as the message attributes of the enqueuer), recording its metrics.
+func (app *Application) traceQueueEvent(e *QueueEvent, carrier propagation.TextMapCarrier, system attribute.KeyValue) (err error) {
+	parent := otel.GetTextMapPropagator().Extract(e.Context, carrier)
+
+	var span trace.Span
+
+	e.Context, span = startSpan(parent, "partida.queue "+e.Name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			system,
+			semconv.MessagingOperationName("process"),
+			semconv.MessagingMessageID(e.ID),
+		),
+	)
+
+	defer span.End()
+
+	started := time.Now()
+
+	err = app.callQueueEvent(e)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	app.recordQueueEventMetrics(e, err, time.Since(started))
+
+	return
+}
+
 // callQueueEvent
 // call the queue method if exists
 func (app *Application) callQueueEvent(e *QueueEvent) (err error) {
 
 	// check if the event method exists
 	if _, ok := app.QueueMethods[e.Name]; !ok {
-		e.Logger.Println("event method function does not exists")
+		e.Logger.Error("event method function does not exists")
 		return fmt.Errorf("event method function does not exists [name: %v]", e.Name)
 	}
 
-	e.Logger.Println("-- executing queue method --")
+	e.Logger.Debug("-- executing queue method --")
 
 	// handle panic at function call
 	defer func() {
 		if rcv := recover(); rcv != nil {
-			e.Logger.Printf("resource method function panicked [recover: %v]", rcv)
+			e.Logger.Error("resource method function panicked", "recover", rcv)
 			err = fmt.Errorf("queue method panic [recover: %v]", rcv)
 		}
 	}()
 
 	err = app.QueueMethods[e.Name](e)
 
-	e.Logger.Println("-- queue method execution ended --")
-	e.Logger.Println("sucessfully executed the resource method function")
+	e.Logger.Debug("-- queue method execution ended --")
+	e.Logger.Debug("sucessfully executed the resource method function")
 
 	return
 }
@@ -0,0 +1,166 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestQueueMessageCorrelation(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	app := newTestApplication()
+	app.QueueLogsWriter = io.Discard
+
+	var got QueueEvent
+
+	app.QueueMethods = map[string]QueueMethod{"notify": func(e *QueueEvent) error {
+		got = *e
+		return nil
+	}}
+
+	// the context of a request being traced
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		requestID string
+		traceID   string
+	}{
+		{"enqueued by a request", contextWithRequestID(ctx, "req-1"), "req-1", traceID.String()},
+		{"enqueued without a request", context.Background(), "message-id", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = QueueEvent{}
+
+			// deliver the attributes of the sent message to the queue handler
+			attributes := make(map[string]events.SQSMessageAttribute)
+			for k, v := range queueMessageAttributes(tt.ctx, "notify") {
+				attributes[k] = events.SQSMessageAttribute{DataType: *v.DataType, StringValue: v.StringValue}
+			}
+
+			err := app.QUEUELambdaHandler(context.Background(), events.SQSEvent{Records: []events.SQSMessage{
+				{MessageId: "message-id", Body: "{}", MessageAttributes: attributes},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.RequestID != tt.requestID || RequestIDFromContext(got.Context) != tt.requestID {
+				t.Errorf("request ID = %q (context %q), want %q", got.RequestID, RequestIDFromContext(got.Context), tt.requestID)
+			}
+
+			// the event span is a child of the trace of the enqueuer
+			if tt.traceID != "" && trace.SpanContextFromContext(got.Context).TraceID().String() != tt.traceID {
+				t.Errorf("trace ID = %v, want %v", trace.SpanContextFromContext(got.Context).TraceID(), tt.traceID)
+			}
+		})
+	}
+}
+
+// fakeQueue
+// an SQS client delivering a single batch of messages, then none until cancelled.
+type fakeQueue struct {
+	sqsiface.SQSAPI
+
+	batch   []*sqs.Message
+	deleted []string
+}
+
+func (q *fakeQueue) ReceiveMessageWithContext(ctx aws.Context, _ *sqs.ReceiveMessageInput, _ ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	if q.batch != nil {
+		batch := q.batch
+		q.batch = nil
+
+		return &sqs.ReceiveMessageOutput{Messages: batch}, nil
+	}
+
+	<-ctx.Done()
+
+	return nil, ctx.Err()
+}
+
+func (q *fakeQueue) DeleteMessageWithContext(_ aws.Context, in *sqs.DeleteMessageInput, _ ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	q.deleted = append(q.deleted, *in.ReceiptHandle)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestConsumeQueue(t *testing.T) {
+	app := newTestApplication()
+	app.QueueLogsWriter = io.Discard
+
+	message := func(id, method string) *sqs.Message {
+		return &sqs.Message{
+			MessageId:         aws.String(id),
+			ReceiptHandle:     aws.String(id),
+			Body:              aws.String("{}"),
+			MessageAttributes: map[string]*sqs.MessageAttributeValue{"METHOD": {DataType: aws.String("String"), StringValue: aws.String(method)}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		batch   []*sqs.Message
+		handled []string
+		deleted []string
+	}{
+		{"handled messages are deleted", []*sqs.Message{message("1", "ok"), message("2", "ok")}, []string{"1", "2"}, []string{"1", "2"}},
+		{"a failure leaves the rest of the batch", []*sqs.Message{message("1", "ok"), message("2", "fail"), message("3", "ok")}, []string{"1", "2"}, []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			var handled []string
+
+			app.QueueMethods = map[string]QueueMethod{
+				"ok": func(e *QueueEvent) error {
+					// the shutdown happens while the first message is handled, which is not cancelled
+					if len(handled) == 0 {
+						cancel()
+
+						if e.Context.Err() != nil {
+							t.Error("the event context was cancelled by the shutdown")
+						}
+					}
+
+					handled = append(handled, e.ID)
+					return nil
+				},
+				"fail": func(e *QueueEvent) error {
+					handled = append(handled, e.ID)
+					return errors.New("failed")
+				},
+			}
+
+			q := &fakeQueue{batch: tt.batch}
+			app.consumeQueue(ctx, q, "queue")
+
+			if !reflect.DeepEqual(handled, tt.handled) || !reflect.DeepEqual(q.deleted, tt.deleted) {
+				t.Errorf("handled = %v, deleted = %v, want %v and %v", handled, q.deleted, tt.handled, tt.deleted)
+			}
+		})
+	}
+}
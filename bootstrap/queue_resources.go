@@ -1,13 +1,18 @@
 package bootstrap
 
-import "log"
+import (
+	"context"
+	"log/slog"
+)
 
 // !!
 type QueueEvent struct {
-	Name   string
-	ID     string
-	Logger *log.Logger
-	Body   map[string]interface{}
+	Name      string
+	ID        string
+	RequestID string          // correlation ID of the request that enqueued the event (or the message ID)
+	Context   context.Context // Lambda invocation context (bounded by the function deadline)
+	Logger    *slog.Logger
+	Body      map[string]interface{}
 }
 
 // !!
@@ -0,0 +1,304 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/ncastellani/partida/utilfunc"
+)
+
+// ReloadSettings
+// define the hot reload of the codes and routes files (see WatchConfig).
+type ReloadSettings struct {
+	Enabled  bool          // watch the files while serving with ListenAndServe
+	Interval time.Duration // polling interval of the files modification, default = 5s
+	SIGHUP   bool          // also reload the files when the process receives a SIGHUP
+}
+
+// configSnapshot
+// hold the response codes and API routes of a configuration, swapped together by the reloads.
+type configSnapshot struct {
+	codes  map[string]Code
+	routes map[string]map[string]APIResource
+}
+
+// config
+// return the current codes and routes under a single lock, so they always
+// belong to the same configuration.
+func (app *Application) config() configSnapshot {
+	if app.configMu == nil {
+		return configSnapshot{app.Codes, app.APIRoutes}
+	}
+
+	app.configMu.RLock()
+	defer app.configMu.RUnlock()
+
+	return configSnapshot{app.Codes, app.APIRoutes}
+}
+
+// configuration
+// return the configuration of the request, taken once from the application
+// so a reload meanwhile does not mix the codes and routes of two configurations.
+func (r *APIRequest) configuration(app *Application) configSnapshot {
+	if r.config == nil {
+		config := app.config()
+		r.config = &config
+	}
+
+	return *r.config
+}
+
+// lockConfig
+// lock the configuration for a change, returning the unlock function.
+func (app *Application) lockConfig() (unlock func()) {
+	if app.configMu == nil {
+		return func() {}
+	}
+
+	app.configMu.Lock()
+
+	return app.configMu.Unlock
+}
+
+// readConfigFiles
+// parse the codes (merged with the default ones) and routes JSON files.
+func readConfigFiles(codesFile, routesFile string) (codes map[string]Code, routes map[string]map[string]APIResource, err error) {
+	if err = utilfunc.ParseJSON(codesFile, &codes); err != nil {
+		return nil, nil, fmt.Errorf("failed to import the codes file: %w", err)
+	}
+
+	if codes == nil {
+		codes = make(map[string]Code)
+	}
+
+	for k, v := range DefaultCodes {
+		codes[k] = v
+	}
+
+	if err = utilfunc.ParseJSON(routesFile, &routes); err != nil {
+		return nil, nil, fmt.Errorf("failed to import the routes file: %w", err)
+	}
+
+	return
+}
+
+// ReloadConfig
+// read the codes and routes files, merge them with the routes registered from
+// the code and validate them. the application only switches to the new
+// configuration if it is valid, keeping the current one otherwise.
+func (app *Application) ReloadConfig() (err error) {
+	defer func() { app.recordConfigReload(err) }()
+
+	if app.codesFile == "" || app.routesFile == "" {
+		return errors.New("the application was not loaded from the codes and routes files")
+	}
+
+	codes, fileRoutes, err := readConfigFiles(app.codesFile, app.routesFile)
+	if err != nil {
+		return err
+	}
+
+	// merge and swap the configuration for the next requests under the lock,
+	// as the routes registered from the code can not change meanwhile
+	unlock := app.lockConfig()
+	defer unlock()
+
+	routes, err := mergeRoutes(fileRoutes, app.codeRoutes)
+	if err != nil {
+		return err
+	}
+
+	if err = app.validateConfig(codes, routes); err != nil {
+		return err
+	}
+
+	app.Codes, app.APIRoutes = codes, routes
+
+	app.Logger.Info("reloaded the codes and API routes files", "available_codes", len(codes), "available_api_routes", len(routes))
+
+	app.checkCursorSecret(routes)
+
+	return nil
+}
+
+// recordConfigReload
+// log a failed reload and count the reload outcome at the metrics registry.
+func (app *Application) recordConfigReload(err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+
+		app.Logger.Error("failed to reload the codes and API routes files, keeping the current ones", "err", err)
+	}
+
+	if app.metrics == nil {
+		return
+	}
+
+	app.metrics.configReloads.WithLabelValues(outcome).Inc()
+	if err == nil {
+		app.metrics.configReloaded.SetToCurrentTime()
+	}
+}
+
+// mergeRoutes
+// merge the routes registered from the code into the routes of the file,
+// failing with the ones defined at both.
+func mergeRoutes(fileRoutes, codeRoutes map[string]map[string]APIResource) (map[string]map[string]APIResource, error) {
+	merged := make(map[string]map[string]APIResource)
+
+	for route, resources := range fileRoutes {
+		merged[route] = make(map[string]APIResource)
+		for method, resource := range resources {
+			merged[route][method] = resource
+		}
+	}
+
+	var conflicts []string
+
+	for route, resources := range codeRoutes {
+		if merged[route] == nil {
+			merged[route] = make(map[string]APIResource)
+		}
+
+		for method, resource := range resources {
+			if _, ok := merged[route][method]; ok {
+				conflicts = append(conflicts, method+" "+route)
+				continue
+			}
+
+			merged[route][method] = resource
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("the routes %v are defined both at the file and the code", conflicts)
+	}
+
+	return merged, nil
+}
+
+// validateConfig
+// check that the codes hold valid HTTP codes and that the functions,
+// middlewares and validators referenced by the routes are registered.
+func (app *Application) validateConfig(codes map[string]Code, routes map[string]map[string]APIResource) error {
+	var problems []string
+
+	for k, v := range codes {
+		if v.HTTPCode < 100 || v.HTTPCode > 599 {
+			problems = append(problems, fmt.Sprintf("code %v has the invalid HTTP code %v", k, v.HTTPCode))
+		}
+	}
+
+	for route, resources := range routes {
+		for method, v := range resources {
+			resource := method + " " + route
+
+			var registered bool
+
+			switch {
+			case v.WebSocket:
+				_, registered = app.APIWebSocketHandlers[v.ResourceMethod]
+			case v.Stream:
+				_, registered = app.APIStreamMethods[v.ResourceMethod]
+			default:
+				_, registered = app.APIMethods[v.ResourceMethod]
+			}
+
+			if !registered {
+				problems = append(problems, fmt.Sprintf("%v references the unknown function %q", resource, v.ResourceMethod))
+			}
+
+			if v.Network.Default != "" && v.Network.Default != "allow" && v.Network.Default != "deny" {
+				problems = append(problems, fmt.Sprintf("%v has the invalid network default %q", resource, v.Network.Default))
+			}
+
+			for _, name := range v.Middlewares {
+				if _, ok := app.APIRouteMiddlewares[name]; !ok {
+					problems = append(problems, fmt.Sprintf("%v references the unknown middleware %q", resource, name))
+				}
+			}
+
+			for _, p := range v.Parameters {
+				for _, name := range p.Validators {
+					if _, ok := app.APIValidators[name]; !ok {
+						problems = append(problems, fmt.Sprintf("%v parameter %v references the unknown validator %q", resource, p.Name, name))
+					}
+				}
+			}
+		}
+	}
+
+	if err := app.checkBuiltinRoutes(routes); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid configuration: %v", problems)
+	}
+
+	return nil
+}
+
+// configFilesStamp
+// return the modification stamp of the codes and routes files.
+func (app *Application) configFilesStamp() (stamp string) {
+	for _, path := range []string{app.codesFile, app.routesFile} {
+		if info, err := os.Stat(path); err == nil {
+			stamp += fmt.Sprintf("%v:%v;", info.ModTime().UnixNano(), info.Size())
+		}
+	}
+
+	return
+}
+
+// WatchConfig
+// reload the codes and routes files when they are modified (by polling them)
+// or when the process receives a SIGHUP, until the context is done.
+func (app *Application) WatchConfig(ctx context.Context) {
+	interval := app.ReloadSettings.Interval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	hangup := make(chan os.Signal, 1)
+	if app.ReloadSettings.SIGHUP {
+		signal.Notify(hangup, syscall.SIGHUP)
+		defer signal.Stop(hangup)
+	}
+
+	app.Logger.Info("watching the codes and API routes files", "interval", interval, "sighup", app.ReloadSettings.SIGHUP)
+
+	stamp := app.configFilesStamp()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			app.Logger.Info("received a SIGHUP, reloading the files")
+		case <-ticker.C:
+			current := app.configFilesStamp()
+			if current == stamp {
+				continue
+			}
+
+			app.Logger.Info("the codes or API routes files were modified, reloading them")
+		}
+
+		stamp = app.configFilesStamp()
+
+		app.ReloadConfig()
+	}
+}
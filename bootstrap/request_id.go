@@ -0,0 +1,102 @@
+package bootstrap
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDSettings
+// define which incoming headers are trusted to carry the correlation ID of a
+// request. when none of them holds a valid value, a new ID is generated.
+type RequestIDSettings struct {
+	TrustedHeaders []string // headers to accept the ID from, in order of preference (ex: "X-Request-Id", "traceparent")
+	MaxLength      int      // max length of an accepted ID, default = 128
+}
+
+// message attribute that carries the correlation ID into the queue events
+const requestIDAttribute = "REQUEST_ID"
+
+// characters allowed on an incoming request ID
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+
+// W3C trace context header: version-traceid-parentid-flags
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// requestIDKey is the context key of the request correlation ID
+type requestIDKey struct{}
+
+// RequestIDFromContext
+// return the correlation ID of the request or queue event that generated the context.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// contextWithRequestID
+// return a copy of the context carrying the correlation ID.
+func contextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// NewRequestID
+// generate a time-ordered and collision-resistant ID (UUIDv7, from crypto randomness).
+func NewRequestID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+
+	return id.String()
+}
+
+// determineRequestID
+// take the request ID from the first trusted header holding a valid value.
+// if none is found, use the fallback ID (provided by the Lambda event) or
+// generate a new one.
+func (app *Application) determineRequestID(headers map[string]string, fallback string) string {
+	for _, name := range app.RequestIDSettings.TrustedHeaders {
+		name = strings.TrimSpace(name)
+		value := strings.TrimSpace(headersCarrier(headers).Get(name))
+		if value == "" {
+			continue
+		}
+
+		// use the trace ID of W3C trace context headers
+		if strings.EqualFold(name, "traceparent") {
+			if m := traceparentPattern.FindStringSubmatch(value); m != nil {
+				return m[1]
+			}
+
+			continue
+		}
+
+		if app.validRequestID(value) {
+			return value
+		}
+	}
+
+	if fallback != "" {
+		return fallback
+	}
+
+	return NewRequestID()
+}
+
+// validRequestID
+// check if an incoming request ID has an acceptable length and characters.
+func (app *Application) validRequestID(id string) bool {
+	max := app.RequestIDSettings.MaxLength
+	if max == 0 {
+		max = 128
+	}
+
+	return len(id) <= max && requestIDPattern.MatchString(id)
+}
@@ -0,0 +1,307 @@
+package bootstrap
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RouteBuilder
+// register the resources of a route from the code, as an alternative to the
+// routes JSON file (see Application.Route).
+type RouteBuilder struct {
+	app   *Application
+	route string
+}
+
+// ResourceBuilder
+// define the policies of a resource registered from the code. each call
+// updates the registered resource.
+type ResourceBuilder struct {
+	app      *Application
+	route    string
+	method   string
+	resource APIResource
+	bind     func(name string) // register the function under the resource method name
+	unbind   func(name string) // remove the function registered under the resource method name
+}
+
+// Route
+// start the registration of the resources of a route from the code (its
+// slashes trimmed, as "/users" is "users"). the resources are merged with the
+// routes JSON file ones, exiting if both define the same route and HTTP method
+// or if a method name is already registered. the method names default to the
+// HTTP method and route (ex: "POST users").
+func (app *Application) Route(route string) *RouteBuilder {
+	return &RouteBuilder{app: app, route: strings.Trim(route, "/")}
+}
+
+// Handle
+// register a resource of the route with the method function.
+func (b *RouteBuilder) Handle(httpMethod string, method APIResourceMethod) *ResourceBuilder {
+	app := b.app
+
+	return b.register(httpMethod, APIResource{}, func(name string) {
+		if app.APIMethods == nil {
+			app.APIMethods = make(map[string]APIResourceMethod)
+		}
+
+		app.APIMethods[name] = method
+	}, func(name string) {
+		delete(app.APIMethods, name)
+	})
+}
+
+// GET
+// register a GET resource of the route.
+func (b *RouteBuilder) GET(method APIResourceMethod) *ResourceBuilder {
+	return b.Handle("GET", method)
+}
+
+// POST
+// register a POST resource of the route.
+func (b *RouteBuilder) POST(method APIResourceMethod) *ResourceBuilder {
+	return b.Handle("POST", method)
+}
+
+// PUT
+// register a PUT resource of the route.
+func (b *RouteBuilder) PUT(method APIResourceMethod) *ResourceBuilder {
+	return b.Handle("PUT", method)
+}
+
+// PATCH
+// register a PATCH resource of the route.
+func (b *RouteBuilder) PATCH(method APIResourceMethod) *ResourceBuilder {
+	return b.Handle("PATCH", method)
+}
+
+// DELETE
+// register a DELETE resource of the route.
+func (b *RouteBuilder) DELETE(method APIResourceMethod) *ResourceBuilder {
+	return b.Handle("DELETE", method)
+}
+
+// Stream
+// register a GET resource of the route answered with Server-Sent Events.
+func (b *RouteBuilder) Stream(method APIStreamMethod) *ResourceBuilder {
+	app := b.app
+
+	return b.register("GET", APIResource{Stream: true}, func(name string) {
+		if app.APIStreamMethods == nil {
+			app.APIStreamMethods = make(map[string]APIStreamMethod)
+		}
+
+		app.APIStreamMethods[name] = method
+	}, func(name string) {
+		delete(app.APIStreamMethods, name)
+	})
+}
+
+// WebSocket
+// register a GET resource of the route accepting WebSocket connection upgrades.
+func (b *RouteBuilder) WebSocket(handler WebSocketHandler) *ResourceBuilder {
+	app := b.app
+
+	return b.register("GET", APIResource{WebSocket: true}, func(name string) {
+		if app.APIWebSocketHandlers == nil {
+			app.APIWebSocketHandlers = make(map[string]WebSocketHandler)
+		}
+
+		app.APIWebSocketHandlers[name] = handler
+	}, func(name string) {
+		delete(app.APIWebSocketHandlers, name)
+	})
+}
+
+// register
+// add the resource to the code routes and bind its function.
+func (b *RouteBuilder) register(httpMethod string, resource APIResource, bind, unbind func(name string)) *ResourceBuilder {
+	resource.ResourceMethod = httpMethod + " " + b.route
+
+	rb := &ResourceBuilder{app: b.app, route: b.route, method: httpMethod, resource: resource, bind: bind, unbind: unbind}
+
+	if b.app.methodNameInUse(resource.ResourceMethod) {
+		b.app.fatal("failed to register the route from the code", "err", fmt.Errorf("the method name %q is already registered", resource.ResourceMethod))
+	}
+
+	if err := b.app.addCodeRoute(b.route, httpMethod, resource); err != nil {
+		b.app.fatal("failed to register the route from the code", "err", err)
+	}
+
+	bind(resource.ResourceMethod)
+
+	return rb
+}
+
+// update
+// apply a change to the resource and store it.
+func (rb *ResourceBuilder) update(change func(res *APIResource)) *ResourceBuilder {
+	change(&rb.resource)
+
+	rb.app.storeCodeRoute(rb.route, rb.method, rb.resource)
+
+	return rb
+}
+
+// Name
+// set the resource method name, as shown at the logs and metrics.
+func (rb *ResourceBuilder) Name(name string) *ResourceBuilder {
+	if name == rb.resource.ResourceMethod {
+		return rb
+	}
+
+	if rb.app.methodNameInUse(name) {
+		rb.app.fatal("failed to name the resource registered from the code", "err", fmt.Errorf("the method name %q is already registered", name))
+	}
+
+	rb.unbind(rb.resource.ResourceMethod)
+	rb.bind(name)
+
+	return rb.update(func(res *APIResource) { res.ResourceMethod = name })
+}
+
+// Authenticated
+// require the authentication token at the resource.
+func (rb *ResourceBuilder) Authenticated() *ResourceBuilder {
+	return rb.update(func(res *APIResource) { res.Authentication = true })
+}
+
+// Network
+// set the network policy of the resource ("allow" or "deny") with its exceptions.
+func (rb *ResourceBuilder) Network(action string, exceptions ...string) *ResourceBuilder {
+	return rb.update(func(res *APIResource) { res.Network = APIResourceNetwork{Default: action, Exceptions: exceptions} })
+}
+
+// Param
+// add a parameter to the resource.
+func (rb *ResourceBuilder) Param(p APIResourceParameter) *ResourceBuilder {
+	return rb.update(func(res *APIResource) { res.Parameters = append(res.Parameters, p) })
+}
+
+// Params
+// add parameters to the resource, as the ones derived by ParametersOf.
+func (rb *ResourceBuilder) Params(params ...APIResourceParameter) *ResourceBuilder {
+	return rb.update(func(res *APIResource) { res.Parameters = append(res.Parameters, params...) })
+}
+
+// Middlewares
+// wrap the resource method with the application route middlewares.
+func (rb *ResourceBuilder) Middlewares(names ...string) *ResourceBuilder {
+	return rb.update(func(res *APIResource) { res.Middlewares = append(res.Middlewares, names...) })
+}
+
+// Timeout
+// set the max execution time of the resource method.
+func (rb *ResourceBuilder) Timeout(d time.Duration) *ResourceBuilder {
+	return rb.update(func(res *APIResource) { res.Timeout = int(d.Milliseconds()) })
+}
+
+// CORS
+// set the cross-origin policy of the resource.
+func (rb *ResourceBuilder) CORS(policy CORSPolicy) *ResourceBuilder {
+	return rb.update(func(res *APIResource) { res.CORS = &policy })
+}
+
+// Cache
+// set the HTTP caching policy of the successful responses of the resource.
+func (rb *ResourceBuilder) Cache(policy APIResourceCache) *ResourceBuilder {
+	return rb.update(func(res *APIResource) { res.Cache = &policy })
+}
+
+// MaxBodySize
+// set the max request body size in bytes of the resource.
+func (rb *ResourceBuilder) MaxBodySize(size int64) *ResourceBuilder {
+	return rb.update(func(res *APIResource) { res.MaxBodySize = size })
+}
+
+// Idempotency
+// replay the responses of the requests with an "Idempotency-Key".
+func (rb *ResourceBuilder) Idempotency(settings APIResourceIdempotency) *ResourceBuilder {
+	return rb.update(func(res *APIResource) { res.Idempotency = &settings })
+}
+
+// Pagination
+// paginate the resource with the "limit" and "cursor" query parameters.
+func (rb *ResourceBuilder) Pagination(settings APIResourcePagination) *ResourceBuilder {
+	rb.update(func(res *APIResource) { res.Pagination = &settings })
+
+	rb.app.checkCursorSecret(map[string]map[string]APIResource{rb.route: {rb.method: rb.resource}})
+
+	return rb
+}
+
+// Fields
+// allow the sparse fieldsets selected by the "fields" query parameter.
+func (rb *ResourceBuilder) Fields(allowed ...string) *ResourceBuilder {
+	return rb.update(func(res *APIResource) { res.Fields = &APIResourceFields{Allowed: allowed} })
+}
+
+// Resource
+// return the registered resource.
+func (rb *ResourceBuilder) Resource() APIResource {
+	return rb.resource
+}
+
+// addCodeRoute
+// add a resource registered from the code into the application routes,
+// failing if the route and HTTP method are already defined.
+func (app *Application) addCodeRoute(route, httpMethod string, resource APIResource) error {
+	unlock := app.lockConfig()
+	defer unlock()
+
+	if _, ok := app.APIRoutes[route][httpMethod]; ok {
+		return fmt.Errorf("the route %v %v is already defined", httpMethod, route)
+	}
+
+	if err := app.checkBuiltinRoutes(map[string]map[string]APIResource{route: nil}); err != nil {
+		return err
+	}
+
+	app.codeRoutes = withResource(app.codeRoutes, route, httpMethod, resource)
+	app.APIRoutes = withResource(app.APIRoutes, route, httpMethod, resource)
+
+	app.checkCursorSecret(map[string]map[string]APIResource{route: {httpMethod: resource}})
+
+	return nil
+}
+
+// storeCodeRoute
+// replace a resource registered from the code at the application routes.
+func (app *Application) storeCodeRoute(route, httpMethod string, resource APIResource) {
+	unlock := app.lockConfig()
+	defer unlock()
+
+	app.codeRoutes = withResource(app.codeRoutes, route, httpMethod, resource)
+	app.APIRoutes = withResource(app.APIRoutes, route, httpMethod, resource)
+}
+
+// withResource
+// return a copy of the routes holding the resource. the routes are never
+// changed in place, as the current ones may be in use by the requests.
+func withResource(routes map[string]map[string]APIResource, route, httpMethod string, resource APIResource) map[string]map[string]APIResource {
+	copied := make(map[string]map[string]APIResource, len(routes)+1)
+	for k, v := range routes {
+		copied[k] = v
+	}
+
+	resources := make(map[string]APIResource, len(routes[route])+1)
+	for k, v := range routes[route] {
+		resources[k] = v
+	}
+
+	resources[httpMethod] = resource
+	copied[route] = resources
+
+	return copied
+}
+
+// methodNameInUse
+// check if a function is registered under the resource method name.
+func (app *Application) methodNameInUse(name string) bool {
+	_, method := app.APIMethods[name]
+	_, stream := app.APIStreamMethods[name]
+	_, handler := app.APIWebSocketHandlers[name]
+
+	return method || stream || handler
+}
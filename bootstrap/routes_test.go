@@ -0,0 +1,77 @@
+package bootstrap
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRouteBuilder(t *testing.T) {
+	app := newTestApplication()
+
+	app.Route("/users/").GET(func(r *APIRequest) Result { return Result{"OK", "listed"} }).
+		Name("listUsers").
+		Param(APIResourceParameter{Name: "team", Kind: "string", QueryParameter: true, Optional: true}).
+		Timeout(time.Second)
+
+	app.Route("users").POST(func(r *APIRequest) Result { return Result{"OK", "created"} })
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		code   string
+	}{
+		{"slashes trimmed from the route", "GET", "/users", "OK"},
+		{"optional query parameter", "GET", "/users?team=a", "OK"},
+		{"other method of the route", "POST", "/users", "OK"},
+		{"unregistered method", "DELETE", "/users", "GEN-0006"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := serveTestRequest(t, app, tt.method, tt.target, "", nil); res.Code != tt.code {
+				t.Errorf("code = %v (%s), want %v", res.Code, res.Data, tt.code)
+			}
+		})
+	}
+
+	resource := app.APIRoutes["users"]["GET"]
+	if resource.ResourceMethod != "listUsers" || resource.Timeout != 1000 || len(resource.Parameters) != 1 {
+		t.Errorf("registered resource = %+v", resource)
+	}
+
+	if _, ok := app.APIMethods["GET users"]; ok {
+		t.Error("the default method name is still registered after the rename")
+	}
+
+	if _, ok := app.APIMethods["POST users"]; !ok {
+		t.Error("the default method name was not registered")
+	}
+}
+
+func TestRouteBuilderWhileServing(t *testing.T) {
+	app := newTestApplication()
+
+	rb := app.Route("users").GET(func(r *APIRequest) Result { return Result{"OK", "listed"} })
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				serveTestRequest(t, app, "GET", "/users", "", nil)
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		rb.MaxBodySize(int64(1024 + i))
+	}
+
+	wg.Wait()
+}
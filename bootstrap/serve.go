@@ -0,0 +1,251 @@
+package bootstrap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// ServeOptions
+// define the listener, timeouts and shutdown of the net/http server (see ListenAndServe).
+type ServeOptions struct {
+	Address string // TCP address to listen at, default = ":$PORT" or ":8080"
+	Socket  string // path of an Unix socket to listen at instead of the TCP address
+
+	ReadTimeout       time.Duration // max duration to read a request (body included), default = 30s
+	ReadHeaderTimeout time.Duration // max duration to read the request headers, default = 10s
+	WriteTimeout      time.Duration // max duration to write a response, default = 60s. not applied to streams and WebSockets
+	IdleTimeout       time.Duration // max duration of idle keep-alive connections, default = 120s
+
+	CertFile string // path of the TLS certificate (PEM), empty to serve cleartext HTTP
+	KeyFile  string // path of the TLS certificate key (PEM)
+
+	DisableHTTP2 bool // do not negotiate HTTP/2 over TLS
+	H2C          bool // serve HTTP/2 over cleartext (as behind the Fly.io proxy "h2_backend")
+
+	ConsumeQueue bool // receive and handle the events of the SQS queue in this process (see ConsumeQueue)
+
+	ShutdownTimeout time.Duration // max duration to drain the in-flight requests, default = 30s (5s at Fly.io)
+	DrainQueue      bool          // also wait for the queue events being handled by the consumer (ConsumeQueue)
+	Signals         []os.Signal   // signals that start the shutdown, default = SIGTERM and SIGINT
+}
+
+// ListenAndServe
+// serve the API pipeline with a net/http server until a shutdown signal is
+// received, then stop accepting connections and drain the in-flight requests
+// (and the queue events of the consumer) until the shutdown timeout.
+func (app *Application) ListenAndServe(opts ServeOptions) error {
+	isFly, region := IsFlyioInstance()
+
+	opts.setDefaults(isFly)
+
+	// check the loaded codes and routes against the registered functions
+	config := app.config()
+
+	if err := app.validateConfig(config.codes, config.routes); err != nil {
+		return err
+	}
+
+	// assemble the server with the API handler
+	var handler http.Handler = http.HandlerFunc(app.APIHTTPHandler)
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       opts.ReadTimeout,
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(app.Logger.Handler(), slog.LevelWarn),
+	}
+
+	if opts.DisableHTTP2 {
+		srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	} else if opts.H2C && opts.CertFile == "" {
+		srv.Handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: opts.IdleTimeout})
+	}
+
+	// close the WebSocket connections, which are not drained by the server
+	srv.RegisterOnShutdown(closeLocalWebSockets)
+
+	// listen at the Unix socket or TCP address
+	network, address := "tcp", opts.Address
+	if opts.Socket != "" {
+		network, address = "unix", opts.Socket
+
+		// remove the socket left by a previous process, but never other files
+		if info, err := os.Lstat(opts.Socket); err == nil {
+			if info.Mode()&os.ModeSocket == 0 {
+				return fmt.Errorf("the socket path %v holds a file that is not a socket", opts.Socket)
+			}
+
+			os.Remove(opts.Socket)
+		}
+	}
+
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+
+	app.Logger.Info("listening for HTTP requests", "network", network, "address", address, "tls", opts.CertFile != "", "flyio", isFly, "region", region)
+
+	// serve the metrics route at its own listener
+	if s := app.MetricsSettings; s.Route != "" && s.Address != "" {
+		metricsLn, err := net.Listen("tcp", s.Address)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+
+		metricsSrv := &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, e *http.Request) {
+				if e.URL.Path != "/"+s.Route {
+					http.NotFound(w, e)
+					return
+				}
+
+				app.serveMetricsRoute(w, e, remoteIP(e))
+			}),
+			ReadHeaderTimeout: opts.ReadHeaderTimeout,
+			ErrorLog:          srv.ErrorLog,
+		}
+
+		app.Logger.Info("listening for the metrics requests", "address", s.Address, "route", s.Route)
+
+		go metricsSrv.Serve(metricsLn)
+		defer metricsSrv.Close()
+	}
+
+	// serve until the listener is closed by the shutdown
+	served := make(chan error, 1)
+
+	go func() {
+		if opts.CertFile != "" {
+			served <- srv.ServeTLS(ln, opts.CertFile, opts.KeyFile)
+		} else {
+			served <- srv.Serve(ln)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), opts.Signals...)
+	defer stop()
+
+	// reload the codes and routes files while serving
+	if app.ReloadSettings.Enabled {
+		go app.WatchConfig(ctx)
+	}
+
+	// consume the queue events until the shutdown
+	consumed := make(chan struct{})
+
+	if opts.ConsumeQueue {
+		go func() {
+			defer close(consumed)
+
+			app.ConsumeQueue(ctx)
+		}()
+	} else {
+		close(consumed)
+	}
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	// drain the in-flight requests and queue events
+	app.Logger.Info("shutting down the HTTP server", "timeout", opts.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		app.Logger.Warn("failed to drain the in-flight requests", "err", err)
+		srv.Close()
+	}
+
+	if opts.DrainQueue {
+		select {
+		case <-consumed:
+		case <-shutdownCtx.Done():
+			app.Logger.Warn("failed to drain the in-flight queue events", "err", shutdownCtx.Err())
+		}
+	}
+
+	app.flushTracing(shutdownCtx)
+
+	app.Logger.Info("HTTP server shut down")
+
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// setDefaults
+// fill the unset options with their default values.
+func (opts *ServeOptions) setDefaults(isFly bool) {
+	if opts.Address == "" {
+		opts.Address = ":8080"
+		if v := os.Getenv("PORT"); v != "" {
+			opts.Address = ":" + v
+		}
+	}
+
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = 30 * time.Second
+	}
+
+	if opts.ReadHeaderTimeout == 0 {
+		opts.ReadHeaderTimeout = 10 * time.Second
+	}
+
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = 60 * time.Second
+	}
+
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = 120 * time.Second
+	}
+
+	// Fly.io kills the machines 5s after the signal by default ("kill_timeout")
+	if opts.ShutdownTimeout == 0 {
+		opts.ShutdownTimeout = 30 * time.Second
+		if isFly {
+			opts.ShutdownTimeout = 5 * time.Second
+		}
+	}
+
+	if len(opts.Signals) == 0 {
+		opts.Signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+}
+
+// closeLocalWebSockets
+// send a "going away" close message to the WebSocket connections of this
+// instance, ending their read loops.
+func closeLocalWebSockets() {
+	localWebSockets.Range(func(_, v any) bool {
+		conn := v.(*WebSocketConnection)
+
+		conn.writeMu.Lock()
+		conn.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(time.Second))
+		conn.writeMu.Unlock()
+
+		return true
+	})
+}
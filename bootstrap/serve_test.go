@@ -0,0 +1,17 @@
+package bootstrap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListenAndServeInvalidConfig(t *testing.T) {
+	app := newTestApplication()
+	app.APIRoutes["users"] = map[string]APIResource{"GET": {ResourceMethod: "listUsers"}}
+
+	// the server is not started with a route referencing an unknown function
+	err := app.ListenAndServe(ServeOptions{Address: "127.0.0.1:0"})
+	if err == nil || !strings.Contains(err.Error(), `unknown function "listUsers"`) {
+		t.Errorf("ListenAndServe() = %v, want the unknown function error", err)
+	}
+}
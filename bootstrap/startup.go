@@ -1,17 +1,20 @@
 package bootstrap
 
 import (
+	"crypto/rand"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/ncastellani/partida/utilfunc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 type Application struct {
-	Logger  *log.Logger            // default application logger
+	Logger  *slog.Logger           // default application logger
 	Path    string                 // path to the directory that this app is running at
 	BuildID string                 // number or "version" of the current compilation ID (like GitHub Actions BuildID)
 	Version string                 // current application version (like 1.0, 2.0.5 ...)
@@ -20,11 +23,73 @@ type Application struct {
 	Codes   map[string]Code        // map of the available response codes
 	Backend Backend                // map to the client application interfaces
 
+	// hot reload of the codes and routes files, swapping them under the lock
+	ReloadSettings ReloadSettings
+	configMu       *sync.RWMutex
+	codesFile      string
+	routesFile     string
+
+	// return panics and internal errors details (with stack traces) to the
+	// clients. when disabled (production), they only get an incident ID
+	DebugMode bool
+
+	// incoming headers accepted as the requests correlation ID
+	RequestIDSettings RequestIDSettings
+
+	// logging settings for the application, requests and queue events loggers
+	LogSettings LogSettings
+
+	// access log settings for the answered API requests
+	AccessLogSettings AccessLogSettings
+
+	// tracing settings for the API pipeline and queue events spans
+	TracingSettings TracingSettings
+	tracerProvider  *sdktrace.TracerProvider
+
+	// metrics settings and the collectors of the API and queue metrics
+	MetricsSettings MetricsSettings
+	metrics         *applicationMetrics
+
 	// API settings
 	APIRoutes     map[string]map[string]APIResource // (done by LoadJSONFiles) path to HTTP method to function method map
 	APILogsWriter io.Writer
 	APIMethods    map[string]APIResourceMethod
 	APIValidators map[string]APIParameterValidator
+	codeRoutes    map[string]map[string]APIResource // routes registered from the code (see Route)
+
+	// streaming methods of the resources answered with Server-Sent Events
+	APIStreamMethods map[string]APIStreamMethod
+	StreamSettings   StreamSettings
+
+	// handlers of the WebSocket resources and the registry of their connections
+	APIWebSocketHandlers map[string]WebSocketHandler
+	WebSocketSettings    WebSocketSettings
+
+	// max request body size in bytes of the resources that do not define their own (default = 10MB)
+	MaxBodySize int64
+
+	// storage of the responses replayed to the requests with an "Idempotency-Key"
+	IdempotencySettings IdempotencySettings
+
+	// page sizes and cursors signing key of the list resources
+	PaginationSettings    PaginationSettings
+	generatedCursorSecret []byte // key generated for this instance when none is configured
+
+	// limits of the built-in batch route (see EnableBatchRoute)
+	BatchSettings BatchSettings
+
+	// built-in liveness, readiness and version routes
+	HealthSettings HealthSettings
+
+	// compression of the response bodies
+	CompressionSettings CompressionSettings
+
+	// cross-origin policy of the resources that do not define their own
+	CORS CORSPolicy
+
+	// API middlewares
+	APIMiddlewares      []APIMiddleware          // wrap the whole pipeline for every request (first is the outermost)
+	APIRouteMiddlewares map[string]APIMiddleware // available to the routes by name, wrapping the resource method
 
 	// Queue settings
 	QueueLogsWriter io.Writer
@@ -33,13 +98,66 @@ type Application struct {
 
 // NewApplication
 // recieve an logger and the paths to the JSON config files. determine the required
-// information to initiate an API and queue handler applcation. will panic if failure.
-func NewApplication(l *log.Logger, config, codes, routes string) (app Application) {
+// information to initiate an API and queue handler applcation. will exit if failure.
+func NewApplication(l *slog.Logger, config, codes, routes string) (app Application) {
 	var err error
 
-	// check if a logger was passed. if not, use the default one
+	// determine the logging settings from the env vars
+	app.LogSettings = LogSettings{
+		Level:  os.Getenv("APP_LOG_LEVEL"),
+		Format: os.Getenv("APP_LOG_FORMAT"),
+	}
+
+	// allow cross-origin requests from any origin by default
+	app.CORS = CORSPolicy{AllowedOrigins: []string{"*"}, MaxAge: 86400}
+
+	// store the idempotency records at the DynamoDB table from the env vars or in memory
+	app.IdempotencySettings.Store = NewMemoryIdempotencyStore()
+	if v := os.Getenv("APP_IDEMPOTENCY_TABLE"); v != "" {
+		app.IdempotencySettings.Store = NewDynamoIdempotencyStore(v)
+	}
+
+	// determine the pagination cursors key from the env vars or generate one for
+	// this instance (warned about once a paginated route is registered)
+	app.PaginationSettings.CursorSecret = []byte(os.Getenv("APP_CURSOR_SECRET"))
+	if len(app.PaginationSettings.CursorSecret) == 0 {
+		app.generatedCursorSecret = make([]byte, 32)
+		rand.Read(app.generatedCursorSecret)
+
+		app.PaginationSettings.CursorSecret = app.generatedCursorSecret
+	}
+
+	// register the WebSocket connections at the DynamoDB table from the env vars
+	if v := os.Getenv("APP_WEBSOCKET_TABLE"); v != "" {
+		app.WebSocketSettings.Registry = NewDynamoConnectionRegistry(v)
+	}
+
+	// determine if the codes and routes files are reloaded while serving from the env vars
+	app.configMu = &sync.RWMutex{}
+	app.ReloadSettings.Enabled = os.Getenv("APP_CONFIG_RELOAD") == "true"
+	app.ReloadSettings.SIGHUP = app.ReloadSettings.Enabled
+
+	// determine if the application runs in debug mode from the env vars
+	app.DebugMode = os.Getenv("APP_DEBUG") == "true"
+
+	// determine the trusted request ID headers from the env vars
+	if v := os.Getenv("APP_REQUEST_ID_HEADERS"); v != "" {
+		app.RequestIDSettings.TrustedHeaders = strings.Split(v, ",")
+	}
+
+	// determine the tracing exporter from the env vars
+	app.TracingSettings = TracingSettings{
+		Exporter: os.Getenv("APP_TRACING_EXPORTER"),
+		Endpoint: os.Getenv("APP_TRACING_ENDPOINT"),
+	}
+
+	// generate the metrics collectors and determine the EMF namespace from the env vars
+	app.metrics = newApplicationMetrics()
+	app.MetricsSettings = MetricsSettings{Namespace: os.Getenv("APP_METRICS_NAMESPACE")}
+
+	// check if a logger was passed. if not, generate one from the settings
 	if l == nil {
-		app.Logger = log.Default()
+		app.Logger = app.LogSettings.NewLogger(os.Stderr)
 	} else {
 		app.Logger = l
 	}
@@ -47,10 +165,10 @@ func NewApplication(l *log.Logger, config, codes, routes string) (app Applicatio
 	// current directory full path
 	app.Path, err = filepath.Abs(filepath.Dir(os.Args[0]))
 	if err != nil {
-		app.Logger.Fatalf("failed to determine the current execution path [err: %v]", err)
+		app.fatal("failed to determine the current execution path", "err", err)
 	}
 
-	app.Logger.Printf("found the current path of this application [pwd: %v]", app.Path)
+	app.Logger.Info("found the current path of this application", "pwd", app.Path)
 
 	// determine the current build ID from env var or generate a new one
 	app.BuildID = os.Getenv("APP_BUILD_ID")
@@ -73,39 +191,27 @@ func NewApplication(l *log.Logger, config, codes, routes string) (app Applicatio
 		os.Setenv("APP_VERSION", app.Version)
 	}
 
-	app.Logger.Printf("determined the current version and build ID [version: %v] [buildID: %v]", app.Version, app.BuildID)
+	app.Logger.Info("determined the current version and build ID", "version", app.Version, "build_id", app.BuildID)
 
 	// parse the general config files
 	err = utilfunc.ParseJSON(app.Path+config, &app.Config)
 	if err != nil {
-		app.Logger.Fatalf("failed to parse the config JSON [err: %v]", err)
+		app.fatal("failed to parse the config JSON", "err", err)
 	}
 
-	app.Logger.Println("configuration file parsed and imported")
+	app.Logger.Info("configuration file parsed and imported")
 
-	// import the API codes
-	var parsedCodes map[string]Code
+	// import the API codes (merged with the default ones) and routes
+	app.codesFile, app.routesFile = app.Path+codes, app.Path+routes
 
-	err = utilfunc.ParseJSON(app.Path+codes, &parsedCodes)
+	app.Codes, app.APIRoutes, err = readConfigFiles(app.codesFile, app.routesFile)
 	if err != nil {
-		app.Logger.Fatalf("failed to import codes JSON file [err: %v]", err)
-	}
-
-	// merge the default codes with the imported ones
-	for k, v := range DefaultCodes {
-		parsedCodes[k] = v
+		app.fatal("failed to import the codes and routes JSON files", "err", err)
 	}
 
-	// set the parsed codes in the application
-	app.Codes = parsedCodes
+	app.Logger.Info("loaded the JSON files with the application codes and API routes", "available_codes", len(app.Codes), "available_api_routes", len(app.APIRoutes))
 
-	// load the API routes to the application
-	err = utilfunc.ParseJSON(app.Path+routes, &app.APIRoutes)
-	if err != nil {
-		app.Logger.Fatalf("failed to import routes JSON file [err: %v]", err)
-	}
-
-	app.Logger.Printf("loaded the JSON files with the application codes and API routes [availableCodes: %v] [availableAPIRoutes: %v]", len(parsedCodes), len(app.APIRoutes))
+	app.checkCursorSecret(app.APIRoutes)
 
 	return
 }
@@ -119,10 +225,17 @@ func (app *Application) CheckForVariables(list []string) {
 	for _, rv := range list {
 		v := os.Getenv(rv)
 		if v == "" {
-			app.Logger.Fatalf("an required environment variable is not set [var: %v]", rv)
+			app.fatal("an required environment variable is not set", "var", rv)
 		}
 
-		app.Logger.Printf("found required environment variable [var: %v]", rv)
+		app.Logger.Info("found required environment variable", "var", rv)
 		app.Vars[rv] = v
 	}
 }
+
+// fatal
+// log an error with the application logger and exit the process.
+func (app *Application) fatal(msg string, args ...any) {
+	app.Logger.Error(msg, args...)
+	os.Exit(1)
+}
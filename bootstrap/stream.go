@@ -0,0 +1,297 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncastellani/partida/utilfunc"
+)
+
+// ErrStreamClosed
+// returned when sending events after the streaming method ended.
+var ErrStreamClosed = errors.New("event stream is closed")
+
+// StreamSettings
+// define the behavior of the Server-Sent Events streams.
+type StreamSettings struct {
+	HeartbeatInterval time.Duration // interval of the comments that keep the connection alive, default = 15s
+}
+
+// APIStreamMethod
+// define a correlation (map) between an string and a streaming function.
+// the returned result is sent as an "error" event when it is not OK.
+// the cookies of the response must be set before the first event.
+type APIStreamMethod func(r *APIRequest, events *EventStream) Result
+
+// Event
+// define a Server-Sent Event. non-string data is sent as JSON.
+type Event struct {
+	Name  string        // event type (empty for "message")
+	ID    string        // event ID, sent back by the clients at the "Last-Event-ID" header
+	Data  interface{}   // event data
+	Retry time.Duration // reconnection time of the client (0 to keep the current one)
+}
+
+// responseStreamer
+// define an adapter response that can be written and flushed progressively.
+type responseStreamer interface {
+	io.Writer
+	start(code int, headers map[string]string, cookies []string) // write the status code, headers and cookies
+	flush() error                                                // send the written data to the client
+}
+
+// EventStream
+// define the "text/event-stream" response of a streaming resource. the
+// response starts with the first event or heartbeat, so the method can
+// still answer with a regular response before sending any event.
+type EventStream struct {
+	mu      sync.Mutex
+	w       responseStreamer
+	headers map[string]string
+	request *APIRequest // request of the method, holding the cookies set before the stream starts
+	started bool
+	closed  bool
+	lastID  string
+}
+
+// LastEventID
+// return the last event ID received by the client, to resume the stream.
+func (s *EventStream) LastEventID() string {
+	return s.lastID
+}
+
+// Send
+// write an event into the stream and flush it to the client.
+func (s *EventStream) Send(e Event) error {
+	data, err := encodeEvent(e)
+	if err != nil {
+		return err
+	}
+
+	return s.write(data)
+}
+
+// encodeEvent
+// encode an event in the "text/event-stream" format.
+func encodeEvent(e Event) (string, error) {
+	var b strings.Builder
+
+	if e.Name != "" {
+		fmt.Fprintf(&b, "event: %v\n", e.Name)
+	}
+
+	if e.ID != "" {
+		fmt.Fprintf(&b, "id: %v\n", e.ID)
+	}
+
+	if e.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %v\n", e.Retry.Milliseconds())
+	}
+
+	// encode the data, with a "data" field per line
+	data, ok := e.Data.(string)
+	if !ok {
+		encoded, err := json.Marshal(e.Data)
+		if err != nil {
+			return "", err
+		}
+
+		data = string(encoded)
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %v\n", line)
+	}
+
+	b.WriteString("\n")
+
+	return b.String(), nil
+}
+
+// comment
+// write a comment line into the stream (ignored by the clients).
+func (s *EventStream) comment(text string) error {
+	return s.write(": " + text + "\n\n")
+}
+
+// write
+// start the stream if needed, then write and flush the data.
+func (s *EventStream) write(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+
+	if !s.started {
+		var cookies []string
+		if s.request != nil {
+			cookies = s.request.cookies
+		}
+
+		s.w.start(http.StatusOK, s.headers, cookies)
+		s.started = true
+	}
+
+	if _, err := io.WriteString(s.w, data); err != nil {
+		return err
+	}
+
+	return s.w.flush()
+}
+
+// finish
+// write the final data (if the stream was started) and prevent further
+// writes, as of methods that keep running after a timeout.
+// return if the stream was started.
+func (s *EventStream) finish(final string) (started bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started && !s.closed && final != "" {
+		io.WriteString(s.w, final)
+		s.w.flush()
+	}
+
+	s.closed = true
+
+	return s.started
+}
+
+// callStreamMethod
+// call the streaming method of the resource with an event stream over the
+// adapter response, sending heartbeats until the method returns.
+func (app *Application) callStreamMethod(r *APIRequest) {
+	if r.Result.Code != "OK" {
+		return
+	}
+
+	method, ok := app.APIStreamMethods[r.Resource.ResourceMethod]
+	if !ok {
+		r.Logger.Error("resource stream function does not exists at the handlers map", "function", r.Resource.ResourceMethod)
+
+		r.updateResult("GEN-0001", r.Resource.ResourceMethod)
+		return
+	}
+
+	if r.streamer == nil {
+		r.Logger.Warn("the adapter of this request can not stream responses")
+
+		r.updateResult("GEN-0028", utilfunc.Empty)
+		return
+	}
+
+	// assemble the stream headers
+	headers := map[string]string{
+		"Content-Type":      "text/event-stream; charset=utf-8",
+		"Cache-Control":     "no-cache",
+		"X-Accel-Buffering": "no",
+		"x-request-id":      r.ID,
+	}
+
+	app.setCORSHeaders(r, headers)
+
+	stream := &EventStream{
+		w:       r.streamer,
+		headers: headers,
+		lastID:  headersCarrier(r.Headers).Get("Last-Event-ID"),
+	}
+
+	// keep the connection alive while the method runs
+	interval := app.StreamSettings.HeartbeatInterval
+	if interval == 0 {
+		interval = 15 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	stop := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := stream.comment("heartbeat"); err != nil {
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	// call the method through the regular method call (timeouts, cancellation and panics)
+	r.Logger.Debug("calling the resource stream method", "last_event_id", stream.lastID)
+
+	r.callMethod(&map[string]APIResourceMethod{
+		r.Resource.ResourceMethod: func(r *APIRequest) Result {
+			stream.mu.Lock()
+			stream.request = r
+			stream.mu.Unlock()
+
+			return method(r, stream)
+		},
+	})
+
+	ticker.Stop()
+	close(stop)
+
+	// once the response was sent, the failures are reported as an event
+	var final string
+
+	if r.Result.Code != "OK" {
+		r.concealInternalData()
+
+		final, _ = encodeEvent(Event{Name: "error", Data: map[string]interface{}{"code": r.Result.Code, "data": r.Result.Data}})
+	}
+
+	if !stream.finish(final) {
+		return
+	}
+
+	r.streamed = true
+	r.Response = APIResponse{HTTPCode: http.StatusOK, Content: []byte{}, Headers: headers}
+
+	r.Logger.Debug("finished the event stream", "code", r.Result.Code)
+
+}
+
+// httpResponseStreamer
+// stream a response with the net/http response writer.
+type httpResponseStreamer struct {
+	w http.ResponseWriter
+}
+
+// start
+// write the status code, headers and cookies of the stream, lifting the server write timeout.
+func (s httpResponseStreamer) start(code int, headers map[string]string, cookies []string) {
+	http.NewResponseController(s.w).SetWriteDeadline(time.Time{})
+
+	for k, v := range headers {
+		s.w.Header().Set(k, v)
+	}
+
+	for _, v := range cookies {
+		s.w.Header().Add("Set-Cookie", v)
+	}
+
+	s.w.WriteHeader(code)
+}
+
+// Write
+// write data into the response.
+func (s httpResponseStreamer) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+// flush
+// send the buffered data to the client.
+func (s httpResponseStreamer) flush() error {
+	return http.NewResponseController(s.w).Flush()
+}
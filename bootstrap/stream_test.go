@@ -0,0 +1,116 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestEncodeEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   Event
+		encoded string
+	}{
+		{"text data", Event{Data: "hello"}, "data: hello\n\n"},
+		{"multiline data", Event{Data: "a\nb"}, "data: a\ndata: b\n\n"},
+		{"JSON data", Event{Data: map[string]int{"n": 1}}, "data: {\"n\":1}\n\n"},
+		{"all fields", Event{Name: "tick", ID: "7", Retry: 2 * time.Second, Data: "x"}, "event: tick\nid: 7\nretry: 2000\ndata: x\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeEvent(tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if encoded != tt.encoded {
+				t.Errorf("encodeEvent() = %q, want %q", encoded, tt.encoded)
+			}
+		})
+	}
+}
+
+func TestStreamMethod(t *testing.T) {
+	app := newTestApplication()
+	app.Codes["EVT-0001"] = Code{HTTPCode: http.StatusConflict, Message: map[string]string{"en-us": "The event stream can not be resumed"}}
+
+	// send an event per item of the "send" query parameter, then return its code
+	app.Route("events").Stream(func(r *APIRequest, s *EventStream) Result {
+		for _, v := range r.Query["send"] {
+			s.Send(Event{ID: string(v), Data: string(v)})
+		}
+
+		return Result{Code: r.Query["code"], Data: s.LastEventID()}
+	})
+
+	tests := []struct {
+		name        string
+		query       string
+		lastEventID string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"events", "send=ab", "", http.StatusOK, "text/event-stream; charset=utf-8", "id: a\ndata: a\n\nid: b\ndata: b\n\n"},
+		{"failure after the first event", "send=a&code=EVT-0001", "", http.StatusOK, "text/event-stream; charset=utf-8", "id: a\ndata: a\n\nevent: error\ndata: {\"code\":\"EVT-0001\",\"data\":\"\"}\n\n"},
+		{"failure before any event", "code=EVT-0001", "7", http.StatusConflict, "application/json; charset=utf-8", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/events?"+tt.query, nil)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+
+			w := httptest.NewRecorder()
+			app.APIHTTPHandler(w, req)
+
+			if w.Code != tt.status || w.Header().Get("Content-Type") != tt.contentType {
+				t.Fatalf("response = %v %q, want %v %q", w.Code, w.Header().Get("Content-Type"), tt.status, tt.contentType)
+			}
+
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+
+			// the regular responses hold the method result
+			if tt.body == "" {
+				var envelope struct {
+					Meta APIMetadata `json:"meta"`
+					Data string      `json:"data"`
+				}
+
+				if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+					t.Fatal(err)
+				}
+
+				if envelope.Meta.Code != "EVT-0001" || envelope.Data != tt.lastEventID {
+					t.Errorf("response = %v %q, want EVT-0001 %q", envelope.Meta.Code, envelope.Data, tt.lastEventID)
+				}
+			}
+		})
+	}
+
+	// adapters that can not stream answer the streaming resources as not supported
+	t.Run("adapter without streaming", func(t *testing.T) {
+		res, err := app.APILambdaHandler(context.Background(), events.APIGatewayProxyRequest{
+			Path:           "/events",
+			RequestContext: events.APIGatewayProxyRequestContext{HTTPMethod: "GET"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != http.StatusNotImplemented {
+			t.Errorf("status = %v, want %v", res.StatusCode, http.StatusNotImplemented)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// name of the instrumentation scope of the spans created by this package
+const tracerName = "github.com/ncastellani/partida/bootstrap"
+
+// TracingSettings
+// define where the spans of the API pipeline and queue events are exported to.
+type TracingSettings struct {
+	Exporter string // spans exporter (otlp or stdout), empty for not exporting
+	Endpoint string // OTLP collector "host:port", default = OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318
+	Insecure bool   // reach the OTLP collector over plain HTTP instead of HTTPS
+}
+
+// StartTracing
+// set up the global tracer provider with the exporter at the tracing settings
+// and the W3C trace context propagator. the returned function flushes and
+// stops the provider, and must be called before the application exits.
+func (app *Application) StartTracing(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	// generate the requested spans exporter
+	var exporter sdktrace.SpanExporter
+
+	switch app.TracingSettings.Exporter {
+	case "otlp":
+		var opts []otlptracehttp.Option
+
+		if app.TracingSettings.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(app.TracingSettings.Endpoint))
+		}
+
+		if app.TracingSettings.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case "stdout":
+		exporter, err = stdouttrace.New()
+	case "":
+		return func(context.Context) error { return nil }, nil
+	default:
+		err = fmt.Errorf("unknown tracing exporter [exporter: %v]", app.TracingSettings.Exporter)
+	}
+
+	if err != nil {
+		return
+	}
+
+	// describe this application as the resource of the spans
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceVersion(app.Version),
+		semconv.ServiceInstanceID(app.BuildID),
+	))
+	if err != nil {
+		return
+	}
+
+	app.tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(app.tracerProvider)
+
+	app.Logger.Info("started the tracer provider", "exporter", app.TracingSettings.Exporter)
+
+	return app.tracerProvider.Shutdown, nil
+}
+
+// flushTracing
+// export the pending spans before a Lambda invocation is frozen.
+func (app *Application) flushTracing(ctx context.Context) {
+	if app.tracerProvider == nil {
+		return
+	}
+
+	if err := app.tracerProvider.ForceFlush(ctx); err != nil {
+		app.Logger.Warn("failed to flush the pending spans", "err", err)
+	}
+}
+
+// startSpan
+// start a span as a child of the passed context using the package tracer.
+func startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// headersCarrier
+// adapt the request headers map into a propagation carrier,
+// looking up keys case insensitively (Lambda events lowercase them).
+type headersCarrier map[string]string
+
+func (c headersCarrier) Get(key string) string {
+	for k, v := range c {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func (c headersCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c headersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// sqsAttributesCarrier
+// adapt the attributes of an SQS message being sent into a propagation carrier.
+type sqsAttributesCarrier map[string]*sqs.MessageAttributeValue
+
+func (c sqsAttributesCarrier) Get(key string) string {
+	if v, ok := c[key]; ok {
+		return aws.StringValue(v.StringValue)
+	}
+
+	return ""
+}
+
+func (c sqsAttributesCarrier) Set(key, value string) {
+	c[key] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+func (c sqsAttributesCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// sqsMessageCarrier
+// adapt the attributes of a recieved SQS message into a propagation carrier.
+type sqsMessageCarrier map[string]events.SQSMessageAttribute
+
+func (c sqsMessageCarrier) Get(key string) string {
+	if v, ok := c[key]; ok && v.StringValue != nil {
+		return *v.StringValue
+	}
+
+	return ""
+}
+
+func (c sqsMessageCarrier) Set(key, value string) {
+	c[key] = events.SQSMessageAttribute{DataType: "String", StringValue: aws.String(value)}
+}
+
+func (c sqsMessageCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
@@ -0,0 +1,691 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/apigatewaymanagementapi"
+	"github.com/gorilla/websocket"
+	"github.com/guregu/dynamo"
+	"github.com/ncastellani/partida/utilfunc"
+)
+
+// ErrConnectionUnreachable
+// returned when sending data to a connection held by another server instance.
+var ErrConnectionUnreachable = errors.New("websocket connection is not reachable from this instance")
+
+// ErrConnectionGone
+// returned when sending data to a connection that was already closed.
+var ErrConnectionGone = errors.New("websocket connection is gone")
+
+// connections upgraded by the net/http adapter at this instance
+var localWebSockets sync.Map
+
+// registry of the applications that do not define one
+var defaultConnectionRegistry = NewMemoryConnectionRegistry()
+
+// WebSocketSettings
+// define the registry of the WebSocket connections and their keepalive.
+type WebSocketSettings struct {
+	Registry     ConnectionRegistry // registry of the open connections, default = in-memory
+	RecordTTL    time.Duration      // time that a connection is kept at the registry, default = 24h
+	PingInterval time.Duration      // interval of the pings to the net/http connections, default = 30s
+	LambdaRoute  string             // route served by the API Gateway WebSocket API, default = index
+}
+
+// WebSocketHandler
+// define the handlers of a WebSocket resource. the connect handler is called
+// after the network and authorization stages and accepts the connection with
+// an OK result. the messages are at the request input, and their non-empty
+// results are sent back to the connection as enveloped responses. the network
+// policy of the resource is checked again for each message and disconnection,
+// which are refused when the connection is no longer registered for the route
+// with the user it was accepted with.
+// at the API Gateway WebSocket API (Lambda), the authorization only runs for
+// the connection, so the message and disconnect requests have no Token and
+// User: identify the user by its registered representation (conn.User, which
+// the connect handler can set before the connection is registered).
+type WebSocketHandler struct {
+	Connect    func(r *APIRequest, conn *WebSocketConnection) Result
+	Message    func(r *APIRequest, conn *WebSocketConnection) Result
+	Disconnect func(r *APIRequest, conn *WebSocketConnection)
+}
+
+// WebSocketConnection
+// define an open WebSocket connection, as stored at the connection registry.
+type WebSocketConnection struct {
+	ID          string    `dynamo:"id,hash"`
+	Route       string    `dynamo:"route" index:"route-index,hash"`
+	User        string    `dynamo:"user"`     // authorized user representation
+	Endpoint    string    `dynamo:"endpoint"` // API Gateway management endpoint (Lambda connections)
+	ConnectedAt time.Time `dynamo:"connected_at"`
+	ExpiresAt   time.Time `dynamo:"expires_at,unixtime"` // DynamoDB TTL attribute
+
+	ws      *websocket.Conn
+	writeMu *sync.Mutex
+}
+
+// ConnectionRegistry
+// define a registry of the open WebSocket connections, used to send data
+// to the connections of any instance and to broadcast to a route.
+type ConnectionRegistry interface {
+	Register(ctx context.Context, conn WebSocketConnection) error
+	Unregister(ctx context.Context, id string) error
+	Get(ctx context.Context, id string) (*WebSocketConnection, error) // nil if not registered
+	List(ctx context.Context, route string) ([]WebSocketConnection, error)
+}
+
+// connectionRegistry
+// return the connection registry of the application.
+func (app *Application) connectionRegistry() ConnectionRegistry {
+	if app.WebSocketSettings.Registry != nil {
+		return app.WebSocketSettings.Registry
+	}
+
+	return defaultConnectionRegistry
+}
+
+// callWebSocketConnect
+// call the connect handler of a WebSocket resource, registering and
+// accepting the connection (upgrading it at the net/http adapter).
+func (app *Application) callWebSocketConnect(r *APIRequest) {
+	if r.Result.Code != "OK" {
+		return
+	}
+
+	handler, ok := app.APIWebSocketHandlers[r.Resource.ResourceMethod]
+	if !ok {
+		r.Logger.Error("resource websocket handler does not exists at the handlers map", "function", r.Resource.ResourceMethod)
+
+		r.updateResult("GEN-0001", r.Resource.ResourceMethod)
+		return
+	}
+
+	if r.connection == nil {
+		r.Logger.Info("websocket resource was not called with a connection upgrade")
+
+		r.updateResult("GEN-0029", utilfunc.Empty)
+		return
+	}
+
+	conn := r.connection
+	conn.Route = r.Path
+	conn.User = accessLogUser(r.User)
+	conn.ConnectedAt = time.Now()
+
+	ttl := app.WebSocketSettings.RecordTTL
+	if ttl == 0 {
+		ttl = 24 * time.Hour
+	}
+
+	conn.ExpiresAt = conn.ConnectedAt.Add(ttl)
+
+	r.Logger = r.Logger.With("connection_id", conn.ID)
+
+	// call the connect handler through the regular method call
+	if handler.Connect != nil {
+		r.callMethod(&map[string]APIResourceMethod{
+			r.Resource.ResourceMethod: func(r *APIRequest) Result { return handler.Connect(r, conn) },
+		})
+
+		if r.Result.Code != "OK" {
+			r.Logger.Info("websocket connection was refused by the connect handler", "code", r.Result.Code)
+			return
+		}
+	}
+
+	// register the connection
+	ctx := context.WithoutCancel(r.Context)
+
+	if err := app.connectionRegistry().Register(ctx, *conn); err != nil {
+		r.updateResult("SE", fmt.Errorf("failed to register the websocket connection: %w", err))
+		return
+	}
+
+	// upgrade the net/http connection (the upgrader answers the failures)
+	if r.upgrade != nil {
+		ws, err := r.upgrade(http.Header{"X-Request-Id": {r.ID}})
+		if err != nil {
+			r.Logger.Info("failed to upgrade the websocket connection", "err", err)
+
+			app.connectionRegistry().Unregister(ctx, conn.ID)
+
+			r.streamed = true
+			r.Response = APIResponse{HTTPCode: http.StatusBadRequest, Content: []byte{}, Headers: map[string]string{}}
+			return
+		}
+
+		conn.ws = ws
+		conn.writeMu = &sync.Mutex{}
+
+		localWebSockets.Store(conn.ID, conn)
+
+		r.streamed = true
+		r.Response = APIResponse{HTTPCode: http.StatusSwitchingProtocols, Content: []byte{}, Headers: map[string]string{}}
+	}
+
+	r.Logger.Info("websocket connection accepted", "route", conn.Route)
+
+}
+
+// serveWebSocket
+// read the messages of an upgraded net/http connection until it is closed,
+// keeping it alive with pings.
+func (app *Application) serveWebSocket(r *APIRequest) {
+	conn := r.connection
+	handler := app.APIWebSocketHandlers[r.Resource.ResourceMethod]
+
+	interval := app.WebSocketSettings.PingInterval
+	if interval == 0 {
+		interval = 30 * time.Second
+	}
+
+	// close the connection on messages larger than the resource max body size
+	conn.ws.SetReadLimit(app.maxBodySize(r))
+
+	// close the connection when the client stops answering the pings
+	conn.ws.SetReadDeadline(time.Now().Add(2 * interval))
+	conn.ws.SetPongHandler(func(string) error {
+		return conn.ws.SetReadDeadline(time.Now().Add(2 * interval))
+	})
+
+	stop := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				conn.writeMu.Lock()
+				err := conn.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval))
+				conn.writeMu.Unlock()
+
+				if err != nil {
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	// handle the messages
+	for {
+		_, data, err := conn.ws.ReadMessage()
+		if err != nil {
+			r.Logger.Debug("websocket connection read ended", "err", err)
+			break
+		}
+
+		app.handleWebSocketMessage(r, conn, handler, data)
+	}
+
+	close(stop)
+
+	// unregister and close the connection
+	localWebSockets.Delete(conn.ID)
+
+	app.handleWebSocketDisconnect(r, conn, handler)
+
+	conn.ws.Close()
+
+}
+
+// webSocketRequest
+// assemble the request of a connection message or disconnection from the
+// connection base request (the connect one or the Lambda event).
+func (app *Application) webSocketRequest(base *APIRequest, conn *WebSocketConnection, kind string) *APIRequest {
+	r := &APIRequest{
+		ID:          NewRequestID(),
+		Context:     base.Context,
+		ContentType: "json",
+		IP:          base.IP,
+		Query:       base.Query,
+		Headers:     base.Headers,
+		Path:        base.Path,
+		Method:      kind,
+		Resource:    base.Resource,
+		Token:       base.Token,
+		User:        base.User,
+		Result:      Result{Code: "OK", Data: utilfunc.Empty},
+		debug:       app.DebugMode,
+	}
+
+	r.Context = contextWithRequestID(r.Context, r.ID)
+	r.Logger = app.LogSettings.NewLogger(app.APILogsWriter).With(
+		slog.String("request_id", r.ID),
+		slog.String("path", r.Path),
+		slog.String("method", r.Method),
+		slog.String("ip", r.IP),
+		slog.String("connection_id", conn.ID),
+	)
+
+	return r
+}
+
+// handleWebSocketMessage
+// call the message handler of a WebSocket resource, sending its non-empty
+// result back to the connection as an enveloped response.
+func (app *Application) handleWebSocketMessage(base *APIRequest, conn *WebSocketConnection, handler WebSocketHandler, data []byte) {
+	if handler.Message == nil {
+		return
+	}
+
+	r := app.webSocketRequest(base, conn, "MESSAGE")
+	r.Input = data
+
+	ctx, span := startSpan(r.Context, "partida.websocket_message")
+	defer span.End()
+
+	r.Context = ctx
+
+	r.Logger.Debug("websocket message recieved", "size", len(data))
+
+	app.verifyWebSocketConnection(r, conn)
+
+	r.callMethod(&map[string]APIResourceMethod{
+		r.Resource.ResourceMethod: func(r *APIRequest) Result { return handler.Message(r, conn) },
+	})
+
+	if r.Result.Code == "OK" && r.Result.Data == nil {
+		return
+	}
+
+	res := r.makeResponse(app)
+
+	if err := app.SendToConnection(ctx, *conn, res.Content); err != nil {
+		r.Logger.Warn("failed to answer the websocket message", "err", err)
+	}
+
+}
+
+// handleWebSocketDisconnect
+// call the disconnect handler of a WebSocket resource and unregister the connection.
+func (app *Application) handleWebSocketDisconnect(base *APIRequest, conn *WebSocketConnection, handler WebSocketHandler) {
+	r := app.webSocketRequest(base, conn, "DISCONNECT")
+
+	app.verifyWebSocketConnection(r, conn)
+
+	if err := app.connectionRegistry().Unregister(r.Context, conn.ID); err != nil {
+		r.Logger.Warn("failed to unregister the websocket connection", "err", err)
+	}
+
+	if r.Result.Code != "OK" {
+		r.Logger.Info("websocket disconnection was not handled", "code", r.Result.Code)
+		return
+	}
+
+	if handler.Disconnect != nil {
+		r.callMethod(&map[string]APIResourceMethod{
+			r.Resource.ResourceMethod: func(r *APIRequest) Result { handler.Disconnect(r, conn); return Result{} },
+		})
+	}
+
+	r.Logger.Info("websocket connection closed")
+
+}
+
+// verifyWebSocketConnection
+// check the network policy of the resource for a message or disconnection of
+// the connection, and that it is still registered for the route with the
+// user it was accepted with.
+func (app *Application) verifyWebSocketConnection(r *APIRequest, conn *WebSocketConnection) {
+
+	// use the current resource of the route, as the routes may have been reloaded
+	for _, v := range r.configuration(app).routes[r.Path] {
+		if v.WebSocket && v.ResourceMethod == r.Resource.ResourceMethod {
+			r.Resource = v
+		}
+	}
+
+	r.verifyNetwork()
+
+	if r.Result.Code != "OK" {
+		return
+	}
+
+	registered, err := app.connectionRegistry().Get(r.Context, conn.ID)
+	if err != nil {
+		r.updateResult("SE", fmt.Errorf("failed to get the websocket connection: %w", err))
+		return
+	}
+
+	if registered == nil || registered.Route != conn.Route || registered.User != conn.User {
+		r.Logger.Info("websocket connection is not registered for this route and user")
+
+		r.updateResult("GEN-0031", utilfunc.Empty)
+		return
+	}
+
+}
+
+// SendToConnection
+// send data to a connection of this instance or of the API Gateway.
+func (app *Application) SendToConnection(ctx context.Context, conn WebSocketConnection, data []byte) error {
+
+	// send to the connections upgraded at this instance
+	if v, ok := localWebSockets.Load(conn.ID); ok {
+		local := v.(*WebSocketConnection)
+
+		local.writeMu.Lock()
+		defer local.writeMu.Unlock()
+
+		return local.ws.WriteMessage(websocket.TextMessage, data)
+	}
+
+	if conn.Endpoint == "" {
+		return ErrConnectionUnreachable
+	}
+
+	// send to the API Gateway connections with its management API
+	client := apigatewaymanagementapi.New(AWSSession, &aws.Config{Endpoint: aws.String(conn.Endpoint)})
+
+	_, err := client.PostToConnectionWithContext(ctx, &apigatewaymanagementapi.PostToConnectionInput{
+		ConnectionId: aws.String(conn.ID),
+		Data:         data,
+	})
+
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == apigatewaymanagementapi.ErrCodeGoneException {
+		return ErrConnectionGone
+	}
+
+	return err
+}
+
+// Send
+// send data to a registered connection by its ID.
+func (app *Application) Send(ctx context.Context, id string, data []byte) error {
+	conn, err := app.connectionRegistry().Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if conn == nil {
+		return ErrConnectionGone
+	}
+
+	err = app.SendToConnection(ctx, *conn, data)
+	if errors.Is(err, ErrConnectionGone) {
+		app.connectionRegistry().Unregister(ctx, id)
+	}
+
+	return err
+}
+
+// Broadcast
+// send data to the registered connections of a route, unregistering the
+// gone ones. return the amount of connections that got the data.
+func (app *Application) Broadcast(ctx context.Context, route string, data []byte) (sent int, err error) {
+	conns, err := app.connectionRegistry().List(ctx, route)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+
+	for _, conn := range conns {
+		err := app.SendToConnection(ctx, conn, data)
+
+		switch {
+		case err == nil:
+			sent++
+		case errors.Is(err, ErrConnectionGone):
+			app.connectionRegistry().Unregister(ctx, conn.ID)
+		case !errors.Is(err, ErrConnectionUnreachable):
+			errs = append(errs, fmt.Errorf("connection %v: %w", conn.ID, err))
+		}
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+// webSocketUpgrader
+// return the upgrader of the net/http connections of a resource, checking
+// the request origin against the resource cross-origin policy. as the browsers
+// do not apply CORS to WebSockets, the resources without their own policy only
+// accept the connections from the same origin (not the application policy).
+func (app *Application) webSocketUpgrader(r *APIRequest) websocket.Upgrader {
+	return websocket.Upgrader{
+		CheckOrigin: func(e *http.Request) bool {
+			origin := e.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+
+			if r.Resource.CORS == nil {
+				u, err := url.Parse(origin)
+				return err == nil && strings.EqualFold(u.Host, e.Host)
+			}
+
+			allowed, _ := r.Resource.CORS.allowsOrigin(origin)
+
+			return allowed
+		},
+	}
+}
+
+// APIWebSocketLambdaHandler
+// handle an inbound AWS Lambda event of an API Gateway WebSocket API. the
+// connections are served by the WebSocket resource of the Lambda route. only
+// the connect event runs the API pipeline (with its authorization), the
+// message and disconnect ones are called without the Token and User, after
+// the network policy and the registered connection checks.
+func (app *Application) APIWebSocketLambdaHandler(ctx context.Context, e events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	route := app.WebSocketSettings.LambdaRoute
+	if route == "" {
+		route = "index"
+	}
+
+	conn := &WebSocketConnection{
+		ID:       e.RequestContext.ConnectionID,
+		Route:    route,
+		Endpoint: fmt.Sprintf("https://%v/%v", e.RequestContext.DomainName, e.RequestContext.Stage),
+	}
+
+	defer app.flushTracing(ctx)
+
+	// perform the connection through the API pipeline
+	if e.RequestContext.EventType == "CONNECT" {
+		r := APIRequest{
+			ID:         app.determineRequestID(e.Headers, e.RequestContext.RequestID),
+			Context:    ctx,
+			IP:         e.RequestContext.Identity.SourceIP,
+			Method:     "GET",
+			Query:      e.QueryStringParameters,
+			Headers:    e.Headers,
+			Path:       route,
+			connection: conn,
+		}
+
+		res := app.handleAPIRequest(&r)
+
+		r.logAnswered(res)
+
+		app.emitRequestEMF(&r)
+
+		return events.APIGatewayProxyResponse{StatusCode: res.HTTPCode, Headers: res.Headers, Body: string(res.Content)}, nil
+	}
+
+	// find the WebSocket resource of the route and the registered connection
+	var resource APIResource
+
+	for _, v := range app.config().routes[route] {
+		if v.WebSocket {
+			resource = v
+		}
+	}
+
+	handler, ok := app.APIWebSocketHandlers[resource.ResourceMethod]
+	if !ok {
+		app.Logger.Error("the websocket route has no resource or handler", "route", route, "function", resource.ResourceMethod)
+
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound}, nil
+	}
+
+	if registered, err := app.connectionRegistry().Get(ctx, conn.ID); err == nil && registered != nil {
+		registered.Endpoint = conn.Endpoint
+		conn = registered
+	}
+
+	base := &APIRequest{
+		Context:  ctx,
+		IP:       e.RequestContext.Identity.SourceIP,
+		Query:    e.QueryStringParameters,
+		Headers:  e.Headers,
+		Path:     route,
+		Resource: resource,
+	}
+
+	switch e.RequestContext.EventType {
+	case "MESSAGE":
+		data, _ := io.ReadAll(lambdaRequestBody(e.Body, e.IsBase64Encoded))
+
+		app.handleWebSocketMessage(base, conn, handler, data)
+	case "DISCONNECT":
+		app.handleWebSocketDisconnect(base, conn, handler)
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+}
+
+// MemoryConnectionRegistry
+// register the connections in the memory of the current instance.
+type MemoryConnectionRegistry struct {
+	mu    sync.Mutex
+	conns map[string]WebSocketConnection
+}
+
+// NewMemoryConnectionRegistry
+// return an empty in-memory connection registry.
+func NewMemoryConnectionRegistry() *MemoryConnectionRegistry {
+	return &MemoryConnectionRegistry{conns: make(map[string]WebSocketConnection)}
+}
+
+// Register
+// store the connection.
+func (m *MemoryConnectionRegistry) Register(ctx context.Context, conn WebSocketConnection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.conns[conn.ID] = conn
+
+	return nil
+}
+
+// Unregister
+// delete the connection.
+func (m *MemoryConnectionRegistry) Unregister(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.conns, id)
+
+	return nil
+}
+
+// Get
+// return the connection, nil if not registered or expired.
+func (m *MemoryConnectionRegistry) Get(ctx context.Context, id string) (*WebSocketConnection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conn, ok := m.conns[id]
+	if !ok || time.Now().After(conn.ExpiresAt) {
+		return nil, nil
+	}
+
+	return &conn, nil
+}
+
+// List
+// return the unexpired connections of the route.
+func (m *MemoryConnectionRegistry) List(ctx context.Context, route string) ([]WebSocketConnection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var conns []WebSocketConnection
+
+	for _, conn := range m.conns {
+		if conn.Route == route && time.Now().Before(conn.ExpiresAt) {
+			conns = append(conns, conn)
+		}
+	}
+
+	return conns, nil
+}
+
+// DynamoConnectionRegistry
+// register the connections at a DynamoDB table (using the DB session), with the
+// "id" string hash key, the "expires_at" attribute enabled as its TTL and a
+// global secondary index with the "route" hash key.
+type DynamoConnectionRegistry struct {
+	Table      string
+	RouteIndex string // name of the route index, default = route-index
+}
+
+// NewDynamoConnectionRegistry
+// return a connection registry for the passed DynamoDB table.
+func NewDynamoConnectionRegistry(table string) *DynamoConnectionRegistry {
+	return &DynamoConnectionRegistry{Table: table, RouteIndex: "route-index"}
+}
+
+// Register
+// put the connection.
+func (d *DynamoConnectionRegistry) Register(ctx context.Context, conn WebSocketConnection) error {
+	return DB.Table(d.Table).Put(conn).RunWithContext(ctx)
+}
+
+// Unregister
+// delete the connection.
+func (d *DynamoConnectionRegistry) Unregister(ctx context.Context, id string) error {
+	return DB.Table(d.Table).Delete("id", id).RunWithContext(ctx)
+}
+
+// Get
+// fetch the connection, nil if not registered or expired.
+func (d *DynamoConnectionRegistry) Get(ctx context.Context, id string) (*WebSocketConnection, error) {
+	var conn WebSocketConnection
+
+	err := DB.Table(d.Table).Get("id", id).OneWithContext(ctx, &conn)
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// DynamoDB deletes the expired items lazily
+	if time.Now().After(conn.ExpiresAt) {
+		return nil, nil
+	}
+
+	return &conn, nil
+}
+
+// List
+// query the unexpired connections of the route at the route index.
+func (d *DynamoConnectionRegistry) List(ctx context.Context, route string) ([]WebSocketConnection, error) {
+	var conns []WebSocketConnection
+
+	err := DB.Table(d.Table).Get("route", route).
+		Index(d.RouteIndex).
+		Filter("$ > ?", "expires_at", time.Now().Unix()).
+		AllWithContext(ctx, &conns)
+
+	return conns, err
+}
@@ -0,0 +1,249 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketMessages(t *testing.T) {
+	app := newTestApplication()
+
+	app.Route("echo").WebSocket(WebSocketHandler{
+		Message: func(r *APIRequest, conn *WebSocketConnection) Result {
+			return Result{"OK", string(r.Input)}
+		},
+	}).MaxBodySize(16)
+
+	srv := httptest.NewServer(http.HandlerFunc(app.APIHTTPHandler))
+	defer srv.Close()
+
+	endpoint := "ws" + strings.TrimPrefix(srv.URL, "http") + "/echo"
+
+	tests := []struct {
+		name    string
+		message string
+		echoed  bool
+	}{
+		{"message within the max size", "hello", true},
+		{"message over the max size closes the connection", strings.Repeat("x", 32), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, _, err := websocket.DefaultDialer.Dial(endpoint, nil)
+			if err != nil {
+				t.Fatalf("failed to connect: %v", err)
+			}
+
+			defer ws.Close()
+
+			if err := ws.WriteMessage(websocket.TextMessage, []byte(tt.message)); err != nil {
+				t.Fatal(err)
+			}
+
+			ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+			_, data, err := ws.ReadMessage()
+
+			if !tt.echoed {
+				if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+					t.Fatalf("read = %s %v, want the connection closed as the message is too big", data, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to read the answer: %v", err)
+			}
+
+			var envelope struct {
+				Meta APIMetadata `json:"meta"`
+				Data string      `json:"data"`
+			}
+
+			if err := json.Unmarshal(data, &envelope); err != nil {
+				t.Fatal(err)
+			}
+
+			if envelope.Meta.Code != "OK" || envelope.Data != tt.message {
+				t.Errorf("answer = %v %q, want OK %q", envelope.Meta.Code, envelope.Data, tt.message)
+			}
+		})
+	}
+}
+
+func TestWebSocketMessageChecks(t *testing.T) {
+	app := newTestApplication()
+	app.WebSocketSettings.Registry = NewMemoryConnectionRegistry()
+
+	app.Route("echo").WebSocket(WebSocketHandler{
+		Message: func(r *APIRequest, conn *WebSocketConnection) Result {
+			return Result{"OK", string(r.Input)}
+		},
+	}).Network("allow")
+
+	srv := httptest.NewServer(http.HandlerFunc(app.APIHTTPHandler))
+	defer srv.Close()
+
+	// change the registered connection or the route network policy after the connection
+	deny := func(conn WebSocketConnection) {
+		app.configMu.Lock()
+		defer app.configMu.Unlock()
+
+		resource := app.APIRoutes["echo"]["GET"]
+		resource.Network = APIResourceNetwork{Default: "deny"}
+
+		app.APIRoutes = map[string]map[string]APIResource{"echo": {"GET": resource}}
+	}
+
+	unregister := func(conn WebSocketConnection) {
+		app.connectionRegistry().Unregister(context.Background(), conn.ID)
+	}
+
+	changeUser := func(conn WebSocketConnection) {
+		conn.User = "other"
+		app.connectionRegistry().Register(context.Background(), conn)
+	}
+
+	tests := []struct {
+		name   string
+		change func(conn WebSocketConnection)
+		code   string
+	}{
+		{"registered connection", func(WebSocketConnection) {}, "OK"},
+		{"route denies the network after the connection", deny, "GEN-0007"},
+		{"connection no longer registered", unregister, "GEN-0031"},
+		{"connection registered for another user", changeUser, "GEN-0031"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := app.config().routes
+			defer func() {
+				app.configMu.Lock()
+				app.APIRoutes = routes
+				app.configMu.Unlock()
+			}()
+
+			ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/echo", nil)
+			if err != nil {
+				t.Fatalf("failed to connect: %v", err)
+			}
+
+			defer ws.Close()
+
+			conns, _ := app.connectionRegistry().List(context.Background(), "echo")
+			if len(conns) != 1 {
+				t.Fatalf("registered connections = %v, want 1", len(conns))
+			}
+
+			tt.change(conns[0])
+
+			if err := ws.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+				t.Fatal(err)
+			}
+
+			ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+			_, data, err := ws.ReadMessage()
+			if err != nil {
+				t.Fatalf("failed to read the answer: %v", err)
+			}
+
+			var envelope struct {
+				Meta APIMetadata `json:"meta"`
+			}
+
+			if err := json.Unmarshal(data, &envelope); err != nil {
+				t.Fatal(err)
+			}
+
+			if envelope.Meta.Code != tt.code {
+				t.Errorf("code = %v, want %v", envelope.Meta.Code, tt.code)
+			}
+
+			// wait for the connection to be closed and unregistered
+			ws.Close()
+
+			for i := 0; i < 100; i++ {
+				if conns, _ := app.connectionRegistry().List(context.Background(), "echo"); len(conns) == 0 {
+					break
+				}
+
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestWebSocketLambdaDisconnect(t *testing.T) {
+	app := newTestApplication()
+	app.WebSocketSettings.Registry = NewMemoryConnectionRegistry()
+	app.WebSocketSettings.LambdaRoute = "echo"
+
+	var disconnected []string
+
+	app.Route("echo").WebSocket(WebSocketHandler{
+		Disconnect: func(r *APIRequest, conn *WebSocketConnection) {
+			disconnected = append(disconnected, conn.ID)
+		},
+	}).Network("deny", "10.0.0.0/8")
+
+	tests := []struct {
+		name       string
+		id         string
+		ip         string
+		registered bool
+		handled    bool
+	}{
+		{"registered connection", "c1", "10.0.0.1", true, true},
+		{"network denied", "c2", "203.0.113.7", true, false},
+		{"connection not registered", "c3", "10.0.0.1", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			disconnected = nil
+
+			if tt.registered {
+				app.connectionRegistry().Register(context.Background(), WebSocketConnection{ID: tt.id, Route: "echo", ExpiresAt: time.Now().Add(time.Hour)})
+			}
+
+			e := events.APIGatewayWebsocketProxyRequest{}
+			e.RequestContext.EventType = "DISCONNECT"
+			e.RequestContext.ConnectionID = tt.id
+			e.RequestContext.Identity.SourceIP = tt.ip
+
+			if _, err := app.APIWebSocketLambdaHandler(context.Background(), e); err != nil {
+				t.Fatal(err)
+			}
+
+			if handled := len(disconnected) == 1; handled != tt.handled {
+				t.Errorf("handled = %v, want %v", handled, tt.handled)
+			}
+
+			if conn, _ := app.connectionRegistry().Get(context.Background(), tt.id); conn != nil {
+				t.Errorf("connection %v is still registered", tt.id)
+			}
+		})
+	}
+}
+
+func TestWebSocketRouteWithoutUpgrade(t *testing.T) {
+	app := newTestApplication()
+
+	app.Route("echo").WebSocket(WebSocketHandler{})
+
+	if res := serveTestRequest(t, app, "GET", "/echo", "", nil); res.Code != "GEN-0029" {
+		t.Errorf("code = %v, want GEN-0029", res.Code)
+	}
+}